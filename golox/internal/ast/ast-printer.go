@@ -0,0 +1,136 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AstPrinter renders an expression as a Lisp-like tree, e.g. `(* (- 1) (group 2))`,
+// to show how it was parsed. Statements in block expressions and lambdas aren't shown.
+type AstPrinter struct{}
+
+func (p AstPrinter) Print(expr Expr) string {
+	return expr.Accept(p).(string)
+}
+
+// parenthesize wraps the name and the parts, which are expressions or strings, in parentheses
+func (p AstPrinter) parenthesize(name string, parts ...any) string {
+	var builder strings.Builder
+	builder.WriteString("(" + name)
+	for _, part := range parts {
+		builder.WriteString(" ")
+		switch part := part.(type) {
+		case nil:
+			// a missing optional expression
+			builder.WriteString("nil")
+		case Expr:
+			builder.WriteString(p.Print(part))
+		case string:
+			builder.WriteString(part)
+		}
+	}
+	builder.WriteString(")")
+	return builder.String()
+}
+
+func (p AstPrinter) exprs(exprs []Expr) []any {
+	parts := make([]any, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = expr
+	}
+	return parts
+}
+
+func (p AstPrinter) VisitAssignExpr(expr *Assign) any {
+	return p.parenthesize("=", expr.Name.Lexeme, expr.Value)
+}
+
+func (p AstPrinter) VisitBinaryExpr(expr *Binary) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitBlockExprExpr(expr *BlockExpr) any {
+	return p.parenthesize("block", expr.Value)
+}
+
+func (p AstPrinter) VisitCallExpr(expr *Call) any {
+	parts := []any{expr.Callee}
+	for i, argument := range expr.Arguments {
+		if i < len(expr.Spread) && expr.Spread[i] {
+			parts = append(parts, p.parenthesize("...", argument))
+		} else {
+			parts = append(parts, argument)
+		}
+	}
+	return p.parenthesize("call", parts...)
+}
+
+func (p AstPrinter) VisitGetExpr(expr *Get) any {
+	if expr.Optional {
+		return p.parenthesize("?.", expr.Object, expr.Name.Lexeme)
+	}
+	return p.parenthesize(".", expr.Object, expr.Name.Lexeme)
+}
+
+func (p AstPrinter) VisitGroupingExpr(expr *Grouping) any {
+	return p.parenthesize("group", expr.Expression)
+}
+
+func (p AstPrinter) VisitLambdaExpr(expr *Lambda) any {
+	params := make([]string, len(expr.Function.Params))
+	for i, param := range expr.Function.Params {
+		params[i] = param.Lexeme
+	}
+	return p.parenthesize("fun", "("+strings.Join(params, " ")+")")
+}
+
+func (p AstPrinter) VisitListExpr(expr *List) any {
+	return p.parenthesize("list", p.exprs(expr.Elements)...)
+}
+
+func (p AstPrinter) VisitLiteralExpr(expr *Literal) any {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(expr.Value)
+}
+
+func (p AstPrinter) VisitLogicalExpr(expr *Logical) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
+}
+
+func (p AstPrinter) VisitRangeExpr(expr *Range) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Start, expr.End)
+}
+
+func (p AstPrinter) VisitSetExpr(expr *Set) any {
+	return p.parenthesize("=", p.parenthesize(".", expr.Object, expr.Name.Lexeme), expr.Value)
+}
+
+func (p AstPrinter) VisitSliceExpr(expr *Slice) any {
+	return p.parenthesize("slice", expr.Object, expr.Start, expr.End)
+}
+
+func (p AstPrinter) VisitSubscriptExpr(expr *Subscript) any {
+	return p.parenthesize("index", expr.Object, expr.Index)
+}
+
+func (p AstPrinter) VisitSuperExpr(expr *Super) any {
+	return p.parenthesize("super", expr.Method.Lexeme)
+}
+
+func (p AstPrinter) VisitThisExpr(expr *This) any {
+	return "this"
+}
+
+func (p AstPrinter) VisitUnaryExpr(expr *Unary) any {
+	return p.parenthesize(expr.Operator.Lexeme, expr.Right)
+}
+
+func (p AstPrinter) VisitVariableExpr(expr *Variable) any {
+	return expr.Name.Lexeme
+}
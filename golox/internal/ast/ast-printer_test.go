@@ -0,0 +1,24 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func printExpr(t *testing.T, code string) string {
+	parser := parser.New(scan(t, code))
+	return ast.AstPrinter{}.Print(parser.ParseExpression())
+}
+
+func TestAstPrinter(t *testing.T) {
+	assert.Equal(t, "(= a (or 1 (call f 2)))", printExpr(t, "a = 1 or f(2)"))
+	assert.Equal(t, "(. (. (call obj) field) name)", printExpr(t, "obj().field.name"))
+	assert.Equal(t, "(= (. this x) (* (- 1) (group (+ 2 3))))", printExpr(t, "this.x = -1 * (2 + 3)"))
+	assert.Equal(t, "(call (?. a b) (... c) hi)", printExpr(t, `a?.b(...c, "hi")`))
+	assert.Equal(t, "(slice (index (list 1 2) 0) nil (- 1))", printExpr(t, "[1, 2][0][:-1]"))
+	assert.Equal(t, "(fun (x y))", printExpr(t, "fun (x, y) { return x; }"))
+	assert.Equal(t, "(super method)", printExpr(t, "super.method"))
+}
@@ -0,0 +1,192 @@
+package ast
+
+import "github.com/michael-go/lox/golox/internal/token"
+
+// Equal reports whether two statements are structurally equal. Tokens are compared by
+// type, lexeme and literal only, so trees parsed from differently laid out sources are equal.
+func Equal(a, b Stmt) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case *Block:
+		b, ok := b.(*Block)
+		return ok && equalStmts(a.Statements, b.Statements)
+	case *Break:
+		b, ok := b.(*Break)
+		return ok && equalTokens(a.Keyword, b.Keyword)
+	case *Class:
+		b, ok := b.(*Class)
+		if !ok || !equalTokens(a.Name, b.Name) || len(a.Methods) != len(b.Methods) {
+			return false
+		}
+		if (a.Superclass == nil) != (b.Superclass == nil) {
+			return false
+		}
+		if a.Superclass != nil && !EqualExpr(a.Superclass, b.Superclass) {
+			return false
+		}
+		for i := range a.Methods {
+			if !Equal(a.Methods[i], b.Methods[i]) {
+				return false
+			}
+		}
+		return true
+	case *Destructure:
+		b, ok := b.(*Destructure)
+		return ok && equalTokenLists(a.Names, b.Names) && EqualExpr(a.Initializer, b.Initializer)
+	case *Eprint:
+		b, ok := b.(*Eprint)
+		return ok && EqualExpr(a.Expression, b.Expression)
+	case *Expression:
+		b, ok := b.(*Expression)
+		return ok && EqualExpr(a.Expression, b.Expression)
+	case *ForEach:
+		b, ok := b.(*ForEach)
+		return ok && equalTokens(a.Keyword, b.Keyword) && equalTokens(a.Name, b.Name) &&
+			EqualExpr(a.Iterable, b.Iterable) && Equal(a.Body, b.Body)
+	case *Function:
+		b, ok := b.(*Function)
+		return ok && equalTokens(a.Name, b.Name) && equalTokenLists(a.Params, b.Params) && equalStmts(a.Body, b.Body) && a.Getter == b.Getter
+	case *If:
+		b, ok := b.(*If)
+		return ok && EqualExpr(a.Condition, b.Condition) && Equal(a.ThenBranch, b.ThenBranch) && Equal(a.ElseBranch, b.ElseBranch)
+	case *MultiAssign:
+		b, ok := b.(*MultiAssign)
+		return ok && equalExprs(a.Targets, b.Targets) && equalExprs(a.Values, b.Values)
+	case *Print:
+		b, ok := b.(*Print)
+		return ok && equalExprs(a.Expressions, b.Expressions)
+	case *Return:
+		b, ok := b.(*Return)
+		return ok && equalTokens(a.Keyword, b.Keyword) && EqualExpr(a.Value, b.Value)
+	case *Var:
+		b, ok := b.(*Var)
+		return ok && equalTokens(a.Name, b.Name) && EqualExpr(a.Initializer, b.Initializer)
+	case *While:
+		b, ok := b.(*While)
+		return ok && equalTokens(a.Keyword, b.Keyword) && EqualExpr(a.Condition, b.Condition) && Equal(a.Body, b.Body) &&
+			EqualExpr(a.Increment, b.Increment) && a.PerIteration == b.PerIteration && Equal(a.ElseBranch, b.ElseBranch)
+	}
+	panic("unexpected statement type")
+}
+
+// EqualExpr reports whether two expressions are structurally equal, see Equal.
+func EqualExpr(a, b Expr) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case *Assign:
+		b, ok := b.(*Assign)
+		return ok && equalTokens(a.Name, b.Name) && EqualExpr(a.Value, b.Value)
+	case *Binary:
+		b, ok := b.(*Binary)
+		return ok && equalTokens(a.Operator, b.Operator) && EqualExpr(a.Left, b.Left) && EqualExpr(a.Right, b.Right)
+	case *Call:
+		b, ok := b.(*Call)
+		if !ok || !EqualExpr(a.Callee, b.Callee) || !equalTokens(a.Paren, b.Paren) || len(a.Arguments) != len(b.Arguments) {
+			return false
+		}
+		for i := range a.Arguments {
+			if !EqualExpr(a.Arguments[i], b.Arguments[i]) || spread(a, i) != spread(b, i) {
+				return false
+			}
+		}
+		return true
+	case *BlockExpr:
+		b, ok := b.(*BlockExpr)
+		return ok && equalStmts(a.Statements, b.Statements) && EqualExpr(a.Value, b.Value)
+	case *Get:
+		b, ok := b.(*Get)
+		return ok && equalTokens(a.Name, b.Name) && a.Optional == b.Optional && EqualExpr(a.Object, b.Object)
+	case *Grouping:
+		b, ok := b.(*Grouping)
+		return ok && EqualExpr(a.Expression, b.Expression)
+	case *Lambda:
+		b, ok := b.(*Lambda)
+		return ok && equalTokens(a.Keyword, b.Keyword) && Equal(a.Function, b.Function)
+	case *List:
+		b, ok := b.(*List)
+		return ok && equalExprs(a.Elements, b.Elements)
+	case *Literal:
+		b, ok := b.(*Literal)
+		return ok && a.Value == b.Value
+	case *Logical:
+		b, ok := b.(*Logical)
+		return ok && equalTokens(a.Operator, b.Operator) && EqualExpr(a.Left, b.Left) && EqualExpr(a.Right, b.Right)
+	case *Range:
+		b, ok := b.(*Range)
+		return ok && equalTokens(a.Operator, b.Operator) && a.Inclusive == b.Inclusive &&
+			EqualExpr(a.Start, b.Start) && EqualExpr(a.End, b.End)
+	case *Set:
+		b, ok := b.(*Set)
+		return ok && equalTokens(a.Name, b.Name) && EqualExpr(a.Object, b.Object) && EqualExpr(a.Value, b.Value)
+	case *Slice:
+		b, ok := b.(*Slice)
+		return ok && EqualExpr(a.Object, b.Object) && EqualExpr(a.Start, b.Start) && EqualExpr(a.End, b.End)
+	case *Subscript:
+		b, ok := b.(*Subscript)
+		return ok && EqualExpr(a.Object, b.Object) && EqualExpr(a.Index, b.Index)
+	case *Super:
+		b, ok := b.(*Super)
+		return ok && equalTokens(a.Keyword, b.Keyword) && equalTokens(a.Method, b.Method)
+	case *This:
+		b, ok := b.(*This)
+		return ok && equalTokens(a.Keyword, b.Keyword)
+	case *Unary:
+		b, ok := b.(*Unary)
+		return ok && equalTokens(a.Operator, b.Operator) && EqualExpr(a.Right, b.Right)
+	case *Variable:
+		b, ok := b.(*Variable)
+		return ok && equalTokens(a.Name, b.Name)
+	}
+	panic("unexpected expression type")
+}
+
+func equalStmts(a, b []Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalExprs(a, b []Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !EqualExpr(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// spread reports whether the call's argument i is spread, a missing entry meaning it isn't
+func spread(call *Call, i int) bool {
+	return i < len(call.Spread) && call.Spread[i]
+}
+
+func equalTokens(a, b token.Token) bool {
+	return a.Type == b.Type && a.Lexeme == b.Lexeme && a.Literal == b.Literal
+}
+
+func equalTokenLists(a, b []token.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalTokens(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/michael-go/lox/golox/internal/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func scan(t *testing.T, code string) []token.Token {
+	scanner := scanner.New(code)
+	tokens, err := scanner.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+	return tokens
+}
+
+func parse(t *testing.T, code string) []ast.Stmt {
+	parser := parser.New(scan(t, code))
+	return parser.Parse()
+}
+
+// tok builds a token for an expected tree, lines are ignored by ast.Equal
+func tok(tokenType token.Type, lexeme string) token.Token {
+	return token.New(tokenType, lexeme, nil, 0)
+}
+
+func equalStmts(a, b []ast.Stmt) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !ast.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestEqual(t *testing.T) {
+	code := `
+		class Counter < Base {
+			add(n) {
+				this.count = this.count + n;
+				return this;
+			}
+		}
+		var c = Counter();
+		if (!c.add(1).count) print "zero"; else print -2;
+	`
+	expected := []ast.Stmt{
+		&ast.Class{
+			Name:       tok(token.IDENTIFIER, "Counter"),
+			Superclass: &ast.Variable{Name: tok(token.IDENTIFIER, "Base")},
+			Methods: []*ast.Function{{
+				Name:   tok(token.IDENTIFIER, "add"),
+				Params: []token.Token{tok(token.IDENTIFIER, "n")},
+				Body: []ast.Stmt{
+					&ast.Expression{Expression: &ast.Set{
+						Object: &ast.This{Keyword: tok(token.THIS, "this")},
+						Name:   tok(token.IDENTIFIER, "count"),
+						Value: &ast.Binary{
+							Left:     &ast.Get{Object: &ast.This{Keyword: tok(token.THIS, "this")}, Name: tok(token.IDENTIFIER, "count")},
+							Operator: tok(token.PLUS, "+"),
+							Right:    &ast.Variable{Name: tok(token.IDENTIFIER, "n")},
+						},
+					}},
+					&ast.Return{Keyword: tok(token.RETURN, "return"), Value: &ast.This{Keyword: tok(token.THIS, "this")}},
+				},
+			}},
+		},
+		&ast.Var{
+			Name:        tok(token.IDENTIFIER, "c"),
+			Initializer: &ast.Call{Callee: &ast.Variable{Name: tok(token.IDENTIFIER, "Counter")}, Paren: tok(token.RIGHT_PAREN, ")"), Spread: []bool{}},
+		},
+		&ast.If{
+			Condition: &ast.Unary{
+				Operator: tok(token.BANG, "!"),
+				Right: &ast.Get{
+					Object: &ast.Call{
+						Callee:    &ast.Get{Object: &ast.Variable{Name: tok(token.IDENTIFIER, "c")}, Name: tok(token.IDENTIFIER, "add")},
+						Paren:     tok(token.RIGHT_PAREN, ")"),
+						Arguments: []ast.Expr{&ast.Literal{Value: 1.0}},
+						Spread:    []bool{false},
+					},
+					Name: tok(token.IDENTIFIER, "count"),
+				},
+			},
+			ThenBranch: &ast.Print{Expressions: []ast.Expr{&ast.Literal{Value: "zero"}}},
+			ElseBranch: &ast.Print{Expressions: []ast.Expr{&ast.Unary{Operator: tok(token.MINUS, "-"), Right: &ast.Literal{Value: 2.0}}}},
+		},
+	}
+
+	assert.True(t, equalStmts(expected, parse(t, code)))
+	// the same program laid out differently
+	assert.True(t, equalStmts(parse(t, code), parse(t, "class Counter<Base{add(n){this.count=this.count+n;return this;}}var c=Counter();if(!c.add(1).count)print \"zero\";else print -2;")))
+}
+
+func TestNotEqual(t *testing.T) {
+	assert.False(t, equalStmts(parse(t, "print 1 + 2;"), parse(t, "print 1 - 2;")))
+	assert.False(t, equalStmts(parse(t, "print 1 + 2;"), parse(t, "print (1 + 2);")))
+	assert.False(t, equalStmts(parse(t, "print a;"), parse(t, "print b;")))
+	assert.False(t, equalStmts(parse(t, "if (a) print 1;"), parse(t, "if (a) print 1; else print 2;")))
+	assert.False(t, equalStmts(parse(t, "class A {}"), parse(t, "class A < B {}")))
+	assert.False(t, equalStmts(parse(t, "f(a);"), parse(t, "f(...a);")))
+	assert.False(t, ast.EqualExpr(&ast.Literal{Value: 1.0}, &ast.Literal{Value: "1"}))
+	assert.True(t, ast.Equal(nil, nil))
+}
+
+func TestEqualCallWithoutSpread(t *testing.T) {
+	call := func(spread []bool) *ast.Call {
+		return &ast.Call{
+			Callee:    &ast.Variable{Name: tok(token.IDENTIFIER, "f")},
+			Paren:     tok(token.RIGHT_PAREN, ")"),
+			Arguments: []ast.Expr{&ast.Literal{Value: 1.0}, &ast.Literal{Value: 2.0}},
+			Spread:    spread,
+		}
+	}
+	assert.True(t, ast.EqualExpr(call(nil), call(nil)))
+	assert.True(t, ast.EqualExpr(call(nil), call([]bool{false, false})))
+	assert.True(t, ast.EqualExpr(call([]bool{false}), call(nil)))
+	assert.False(t, ast.EqualExpr(call(nil), call([]bool{false, true})))
+}
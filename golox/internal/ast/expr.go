@@ -20,21 +20,39 @@ type Binary struct {
 	Right    Expr
 }
 
+type BlockExpr struct {
+	Brace      token.Token
+	Statements []Stmt
+	Value      Expr
+}
+
 type Call struct {
 	Callee    Expr
 	Paren     token.Token
 	Arguments []Expr
+	Spread    []bool
 }
 
 type Get struct {
-	Object Expr
-	Name   token.Token
+	Object   Expr
+	Name     token.Token
+	Optional bool
 }
 
 type Grouping struct {
 	Expression Expr
 }
 
+type Lambda struct {
+	Keyword  token.Token
+	Function *Function
+}
+
+type List struct {
+	Bracket  token.Token
+	Elements []Expr
+}
+
 type Literal struct {
 	Value any
 }
@@ -45,12 +63,32 @@ type Logical struct {
 	Right    Expr
 }
 
+type Range struct {
+	Start     Expr
+	Operator  token.Token
+	End       Expr
+	Inclusive bool
+}
+
 type Set struct {
 	Object Expr
 	Name   token.Token
 	Value  Expr
 }
 
+type Slice struct {
+	Object  Expr
+	Bracket token.Token
+	Start   Expr
+	End     Expr
+}
+
+type Subscript struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+}
+
 type Super struct {
 	Keyword token.Token
 	Method  token.Token
@@ -72,12 +110,18 @@ type Variable struct {
 type ExprVisitor interface {
 	VisitAssignExpr(expr *Assign) any
 	VisitBinaryExpr(expr *Binary) any
+	VisitBlockExprExpr(expr *BlockExpr) any
 	VisitCallExpr(expr *Call) any
 	VisitGetExpr(expr *Get) any
 	VisitGroupingExpr(expr *Grouping) any
+	VisitLambdaExpr(expr *Lambda) any
+	VisitListExpr(expr *List) any
 	VisitLiteralExpr(expr *Literal) any
 	VisitLogicalExpr(expr *Logical) any
+	VisitRangeExpr(expr *Range) any
 	VisitSetExpr(expr *Set) any
+	VisitSliceExpr(expr *Slice) any
+	VisitSubscriptExpr(expr *Subscript) any
 	VisitSuperExpr(expr *Super) any
 	VisitThisExpr(expr *This) any
 	VisitUnaryExpr(expr *Unary) any
@@ -92,6 +136,10 @@ func (expr *Binary) Accept(visitor ExprVisitor) any {
 	return visitor.VisitBinaryExpr(expr)
 }
 
+func (expr *BlockExpr) Accept(visitor ExprVisitor) any {
+	return visitor.VisitBlockExprExpr(expr)
+}
+
 func (expr *Call) Accept(visitor ExprVisitor) any {
 	return visitor.VisitCallExpr(expr)
 }
@@ -104,6 +152,14 @@ func (expr *Grouping) Accept(visitor ExprVisitor) any {
 	return visitor.VisitGroupingExpr(expr)
 }
 
+func (expr *Lambda) Accept(visitor ExprVisitor) any {
+	return visitor.VisitLambdaExpr(expr)
+}
+
+func (expr *List) Accept(visitor ExprVisitor) any {
+	return visitor.VisitListExpr(expr)
+}
+
 func (expr *Literal) Accept(visitor ExprVisitor) any {
 	return visitor.VisitLiteralExpr(expr)
 }
@@ -112,10 +168,22 @@ func (expr *Logical) Accept(visitor ExprVisitor) any {
 	return visitor.VisitLogicalExpr(expr)
 }
 
+func (expr *Range) Accept(visitor ExprVisitor) any {
+	return visitor.VisitRangeExpr(expr)
+}
+
 func (expr *Set) Accept(visitor ExprVisitor) any {
 	return visitor.VisitSetExpr(expr)
 }
 
+func (expr *Slice) Accept(visitor ExprVisitor) any {
+	return visitor.VisitSliceExpr(expr)
+}
+
+func (expr *Subscript) Accept(visitor ExprVisitor) any {
+	return visitor.VisitSubscriptExpr(expr)
+}
+
 func (expr *Super) Accept(visitor ExprVisitor) any {
 	return visitor.VisitSuperExpr(expr)
 }
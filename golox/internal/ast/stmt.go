@@ -13,20 +13,42 @@ type Block struct {
 	Statements []Stmt
 }
 
+type Break struct {
+	Keyword token.Token
+}
+
 type Class struct {
 	Name       token.Token
 	Superclass *Variable
 	Methods    []*Function
 }
 
+type Destructure struct {
+	Paren       token.Token
+	Names       []token.Token
+	Initializer Expr
+}
+
+type Eprint struct {
+	Expression Expr
+}
+
 type Expression struct {
 	Expression Expr
 }
 
+type ForEach struct {
+	Keyword  token.Token
+	Name     token.Token
+	Iterable Expr
+	Body     Stmt
+}
+
 type Function struct {
 	Name   token.Token
 	Params []token.Token
 	Body   []Stmt
+	Getter bool
 }
 
 type If struct {
@@ -35,8 +57,14 @@ type If struct {
 	ElseBranch Stmt
 }
 
+type MultiAssign struct {
+	Targets []Expr
+	Equals  token.Token
+	Values  []Expr
+}
+
 type Print struct {
-	Expression Expr
+	Expressions []Expr
 }
 
 type Return struct {
@@ -50,16 +78,25 @@ type Var struct {
 }
 
 type While struct {
-	Condition Expr
-	Body      Stmt
+	Keyword      token.Token
+	Condition    Expr
+	Body         Stmt
+	Increment    Expr
+	PerIteration bool
+	ElseBranch   Stmt
 }
 
 type StmtVisitor interface {
 	VisitBlockStmt(stmt *Block) any
+	VisitBreakStmt(stmt *Break) any
 	VisitClassStmt(stmt *Class) any
+	VisitDestructureStmt(stmt *Destructure) any
+	VisitEprintStmt(stmt *Eprint) any
 	VisitExpressionStmt(stmt *Expression) any
+	VisitForEachStmt(stmt *ForEach) any
 	VisitFunctionStmt(stmt *Function) any
 	VisitIfStmt(stmt *If) any
+	VisitMultiAssignStmt(stmt *MultiAssign) any
 	VisitPrintStmt(stmt *Print) any
 	VisitReturnStmt(stmt *Return) any
 	VisitVarStmt(stmt *Var) any
@@ -70,14 +107,30 @@ func (stmt *Block) Accept(visitor StmtVisitor) any {
 	return visitor.VisitBlockStmt(stmt)
 }
 
+func (stmt *Break) Accept(visitor StmtVisitor) any {
+	return visitor.VisitBreakStmt(stmt)
+}
+
 func (stmt *Class) Accept(visitor StmtVisitor) any {
 	return visitor.VisitClassStmt(stmt)
 }
 
+func (stmt *Destructure) Accept(visitor StmtVisitor) any {
+	return visitor.VisitDestructureStmt(stmt)
+}
+
+func (stmt *Eprint) Accept(visitor StmtVisitor) any {
+	return visitor.VisitEprintStmt(stmt)
+}
+
 func (stmt *Expression) Accept(visitor StmtVisitor) any {
 	return visitor.VisitExpressionStmt(stmt)
 }
 
+func (stmt *ForEach) Accept(visitor StmtVisitor) any {
+	return visitor.VisitForEachStmt(stmt)
+}
+
 func (stmt *Function) Accept(visitor StmtVisitor) any {
 	return visitor.VisitFunctionStmt(stmt)
 }
@@ -86,6 +139,10 @@ func (stmt *If) Accept(visitor StmtVisitor) any {
 	return visitor.VisitIfStmt(stmt)
 }
 
+func (stmt *MultiAssign) Accept(visitor StmtVisitor) any {
+	return visitor.VisitMultiAssignStmt(stmt)
+}
+
 func (stmt *Print) Accept(visitor StmtVisitor) any {
 	return visitor.VisitPrintStmt(stmt)
 }
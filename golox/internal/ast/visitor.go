@@ -0,0 +1,224 @@
+package ast
+
+// Visitor visits both statements and expressions.
+type Visitor interface {
+	StmtVisitor
+	ExprVisitor
+}
+
+// BaseVisitor implements Visitor by visiting the children of every node and returning nil,
+// so a pass can embed it and only override the methods of the nodes it cares about.
+// Go has no virtual methods, so Self must be set to the embedding pass for children to be
+// dispatched to its overrides. An override can call the BaseVisitor method to keep
+// visiting the node's children.
+type BaseVisitor struct {
+	Self Visitor
+}
+
+// Walk visits the statements with Self.
+func (v *BaseVisitor) Walk(statements []Stmt) {
+	for _, statement := range statements {
+		v.stmt(statement)
+	}
+}
+
+func (v *BaseVisitor) stmt(stmt Stmt) {
+	// a statement with a syntax error is nil
+	if stmt != nil {
+		stmt.Accept(v.Self)
+	}
+}
+
+func (v *BaseVisitor) expr(expr Expr) {
+	if expr != nil {
+		expr.Accept(v.Self)
+	}
+}
+
+func (v *BaseVisitor) VisitBlockStmt(stmt *Block) any {
+	v.Walk(stmt.Statements)
+	return nil
+}
+
+func (v *BaseVisitor) VisitBreakStmt(stmt *Break) any {
+	return nil
+}
+
+func (v *BaseVisitor) VisitClassStmt(stmt *Class) any {
+	if stmt.Superclass != nil {
+		v.expr(stmt.Superclass)
+	}
+	for _, method := range stmt.Methods {
+		v.stmt(method)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitDestructureStmt(stmt *Destructure) any {
+	v.expr(stmt.Initializer)
+	return nil
+}
+
+func (v *BaseVisitor) VisitEprintStmt(stmt *Eprint) any {
+	v.expr(stmt.Expression)
+	return nil
+}
+
+func (v *BaseVisitor) VisitExpressionStmt(stmt *Expression) any {
+	v.expr(stmt.Expression)
+	return nil
+}
+
+func (v *BaseVisitor) VisitForEachStmt(stmt *ForEach) any {
+	v.expr(stmt.Iterable)
+	v.stmt(stmt.Body)
+	return nil
+}
+
+func (v *BaseVisitor) VisitFunctionStmt(stmt *Function) any {
+	v.Walk(stmt.Body)
+	return nil
+}
+
+func (v *BaseVisitor) VisitIfStmt(stmt *If) any {
+	v.expr(stmt.Condition)
+	v.stmt(stmt.ThenBranch)
+	v.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (v *BaseVisitor) VisitMultiAssignStmt(stmt *MultiAssign) any {
+	for _, value := range stmt.Values {
+		v.expr(value)
+	}
+	for _, target := range stmt.Targets {
+		v.expr(target)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitPrintStmt(stmt *Print) any {
+	for _, expr := range stmt.Expressions {
+		v.expr(expr)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitReturnStmt(stmt *Return) any {
+	v.expr(stmt.Value)
+	return nil
+}
+
+func (v *BaseVisitor) VisitVarStmt(stmt *Var) any {
+	v.expr(stmt.Initializer)
+	return nil
+}
+
+func (v *BaseVisitor) VisitWhileStmt(stmt *While) any {
+	v.expr(stmt.Condition)
+	v.stmt(stmt.Body)
+	v.expr(stmt.Increment)
+	v.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (v *BaseVisitor) VisitAssignExpr(expr *Assign) any {
+	v.expr(expr.Value)
+	return nil
+}
+
+func (v *BaseVisitor) VisitBinaryExpr(expr *Binary) any {
+	v.expr(expr.Left)
+	v.expr(expr.Right)
+	return nil
+}
+
+func (v *BaseVisitor) VisitCallExpr(expr *Call) any {
+	v.expr(expr.Callee)
+	for _, arg := range expr.Arguments {
+		v.expr(arg)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitGetExpr(expr *Get) any {
+	v.expr(expr.Object)
+	return nil
+}
+
+func (v *BaseVisitor) VisitGroupingExpr(expr *Grouping) any {
+	v.expr(expr.Expression)
+	return nil
+}
+
+func (v *BaseVisitor) VisitBlockExprExpr(expr *BlockExpr) any {
+	v.Walk(expr.Statements)
+	if expr.Value != nil {
+		v.expr(expr.Value)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitLambdaExpr(expr *Lambda) any {
+	v.stmt(expr.Function)
+	return nil
+}
+
+func (v *BaseVisitor) VisitListExpr(expr *List) any {
+	for _, element := range expr.Elements {
+		v.expr(element)
+	}
+	return nil
+}
+
+func (v *BaseVisitor) VisitLiteralExpr(expr *Literal) any {
+	return nil
+}
+
+func (v *BaseVisitor) VisitRangeExpr(expr *Range) any {
+	v.expr(expr.Start)
+	v.expr(expr.End)
+	return nil
+}
+
+func (v *BaseVisitor) VisitLogicalExpr(expr *Logical) any {
+	v.expr(expr.Left)
+	v.expr(expr.Right)
+	return nil
+}
+
+func (v *BaseVisitor) VisitSetExpr(expr *Set) any {
+	v.expr(expr.Object)
+	v.expr(expr.Value)
+	return nil
+}
+
+func (v *BaseVisitor) VisitSliceExpr(expr *Slice) any {
+	v.expr(expr.Object)
+	v.expr(expr.Start)
+	v.expr(expr.End)
+	return nil
+}
+
+func (v *BaseVisitor) VisitSubscriptExpr(expr *Subscript) any {
+	v.expr(expr.Object)
+	v.expr(expr.Index)
+	return nil
+}
+
+func (v *BaseVisitor) VisitSuperExpr(expr *Super) any {
+	return nil
+}
+
+func (v *BaseVisitor) VisitThisExpr(expr *This) any {
+	return nil
+}
+
+func (v *BaseVisitor) VisitUnaryExpr(expr *Unary) any {
+	v.expr(expr.Right)
+	return nil
+}
+
+func (v *BaseVisitor) VisitVariableExpr(expr *Variable) any {
+	return nil
+}
@@ -0,0 +1,57 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+type callCounter struct {
+	ast.BaseVisitor
+	calls int
+}
+
+func (c *callCounter) VisitCallExpr(expr *ast.Call) any {
+	c.calls++
+	return c.BaseVisitor.VisitCallExpr(expr)
+}
+
+func countCalls(statements []ast.Stmt) int {
+	counter := &callCounter{}
+	counter.Self = counter
+	counter.Walk(statements)
+	return counter.calls
+}
+
+func TestBaseVisitor(t *testing.T) {
+	code := `
+		fun f(x) {
+			return g(x)(h());
+		}
+		class A < B {
+			m() {
+				while (check()) this.x = f(1);
+			}
+		}
+		var l = fun () { print f(f(2)); };
+		if (a) {} else eprint a?.b(...c);
+	`
+	assert.Equal(t, 8, countCalls(parse(t, code)))
+	assert.Equal(t, 0, countCalls(parse(t, "print 1 + 2;")))
+}
+
+func TestExprVisitorCoversInterpretedNodes(t *testing.T) {
+	visitor := reflect.TypeOf((*ast.ExprVisitor)(nil)).Elem()
+	for _, node := range []ast.Expr{
+		&ast.Binary{}, &ast.Grouping{}, &ast.Literal{}, &ast.Unary{}, &ast.Variable{}, &ast.Assign{},
+		&ast.Logical{}, &ast.Call{}, &ast.Get{}, &ast.Set{}, &ast.This{}, &ast.Super{},
+	} {
+		name := reflect.TypeOf(node).Elem().Name()
+		method, ok := visitor.MethodByName("Visit" + name + "Expr")
+		if assert.True(t, ok, name) {
+			assert.Equal(t, reflect.TypeOf(node), method.Type.In(0), name)
+		}
+	}
+}
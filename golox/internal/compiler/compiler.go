@@ -0,0 +1,350 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/token"
+)
+
+type OpCode int
+
+const (
+	OP_CONSTANT OpCode = iota
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+	OP_GET_GLOBAL
+	OP_EQUAL
+	OP_GREATER
+	OP_LESS
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NEGATE
+	OP_NOT
+	OP_PRINT
+	OP_JUMP
+	OP_JUMP_IF_FALSE
+)
+
+var opNames = map[OpCode]string{
+	OP_CONSTANT:      "OP_CONSTANT",
+	OP_NIL:           "OP_NIL",
+	OP_TRUE:          "OP_TRUE",
+	OP_FALSE:         "OP_FALSE",
+	OP_POP:           "OP_POP",
+	OP_GET_GLOBAL:    "OP_GET_GLOBAL",
+	OP_EQUAL:         "OP_EQUAL",
+	OP_GREATER:       "OP_GREATER",
+	OP_LESS:          "OP_LESS",
+	OP_ADD:           "OP_ADD",
+	OP_SUBTRACT:      "OP_SUBTRACT",
+	OP_MULTIPLY:      "OP_MULTIPLY",
+	OP_DIVIDE:        "OP_DIVIDE",
+	OP_NEGATE:        "OP_NEGATE",
+	OP_NOT:           "OP_NOT",
+	OP_PRINT:         "OP_PRINT",
+	OP_JUMP:          "OP_JUMP",
+	OP_JUMP_IF_FALSE: "OP_JUMP_IF_FALSE",
+}
+
+func (op OpCode) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OpCode(%d)", int(op))
+}
+
+// Instr is a single instruction. Operand holds the constant for OP_CONSTANT, the
+// variable name for OP_GET_GLOBAL and the target index for jumps.
+type Instr struct {
+	Op      OpCode
+	Operand any
+}
+
+type CompileError struct {
+	message string
+}
+
+func (e CompileError) Error() string {
+	return e.message
+}
+
+// Compile lowers the AST into a flat instruction list, the way clox does. Only
+// expressions and print statements are supported so far.
+func Compile(statements []ast.Stmt) (instrs []Instr, err error) {
+	c := compiler{}
+	defer func() {
+		if r := recover(); r != nil {
+			compileErr, ok := r.(CompileError)
+			if !ok {
+				panic(r)
+			}
+			instrs, err = nil, compileErr
+		}
+	}()
+
+	for _, statement := range statements {
+		// a statement with a syntax error is nil
+		if statement != nil {
+			statement.Accept(&c)
+		}
+	}
+	return c.code, nil
+}
+
+// Disassemble renders the instructions one per line, prefixed by their index.
+func Disassemble(instrs []Instr) string {
+	var builder strings.Builder
+	for i, instr := range instrs {
+		fmt.Fprintf(&builder, "%04d %s", i, instr.Op)
+		switch instr.Op {
+		case OP_CONSTANT:
+			fmt.Fprintf(&builder, " %s", formatConstant(instr.Operand))
+		case OP_GET_GLOBAL:
+			fmt.Fprintf(&builder, " '%v'", instr.Operand)
+		case OP_JUMP, OP_JUMP_IF_FALSE:
+			fmt.Fprintf(&builder, " -> %04d", instr.Operand)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func formatConstant(value any) string {
+	if str, ok := value.(string); ok {
+		return fmt.Sprintf("%q", str)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+type compiler struct {
+	code []Instr
+}
+
+func (c *compiler) emit(op OpCode, operand any) int {
+	c.code = append(c.code, Instr{Op: op, Operand: operand})
+	return len(c.code) - 1
+}
+
+// patchJump points the jump at index to the next instruction to be emitted
+func (c *compiler) patchJump(index int) {
+	c.code[index].Operand = len(c.code)
+}
+
+func (c *compiler) expr(expr ast.Expr) {
+	expr.Accept(c)
+}
+
+func unsupported(what string) CompileError {
+	return CompileError{message: fmt.Sprintf("Can't compile %s yet.", what)}
+}
+
+func (c *compiler) VisitBlockStmt(stmt *ast.Block) any {
+	panic(unsupported("blocks"))
+}
+
+func (c *compiler) VisitBreakStmt(stmt *ast.Break) any {
+	panic(unsupported("break statements"))
+}
+
+func (c *compiler) VisitClassStmt(stmt *ast.Class) any {
+	panic(unsupported("classes"))
+}
+
+func (c *compiler) VisitExpressionStmt(stmt *ast.Expression) any {
+	c.expr(stmt.Expression)
+	c.emit(OP_POP, nil)
+	return nil
+}
+
+func (c *compiler) VisitFunctionStmt(stmt *ast.Function) any {
+	panic(unsupported("functions"))
+}
+
+func (c *compiler) VisitIfStmt(stmt *ast.If) any {
+	panic(unsupported("if statements"))
+}
+
+func (c *compiler) VisitPrintStmt(stmt *ast.Print) any {
+	if len(stmt.Expressions) > 1 {
+		panic(unsupported("printing multiple values"))
+	}
+	c.expr(stmt.Expressions[0])
+	c.emit(OP_PRINT, nil)
+	return nil
+}
+
+func (c *compiler) VisitEprintStmt(stmt *ast.Eprint) any {
+	panic(unsupported("eprint statements"))
+}
+
+func (c *compiler) VisitReturnStmt(stmt *ast.Return) any {
+	panic(unsupported("return statements"))
+}
+
+func (c *compiler) VisitMultiAssignStmt(stmt *ast.MultiAssign) any {
+	panic(unsupported("multiple assignments"))
+}
+
+func (c *compiler) VisitDestructureStmt(stmt *ast.Destructure) any {
+	panic(unsupported("destructuring"))
+}
+
+func (c *compiler) VisitForEachStmt(stmt *ast.ForEach) any {
+	panic(unsupported("for-in loops"))
+}
+
+func (c *compiler) VisitVarStmt(stmt *ast.Var) any {
+	panic(unsupported("variable declarations"))
+}
+
+func (c *compiler) VisitWhileStmt(stmt *ast.While) any {
+	panic(unsupported("while loops"))
+}
+
+func (c *compiler) VisitAssignExpr(expr *ast.Assign) any {
+	panic(unsupported("assignments"))
+}
+
+func (c *compiler) VisitBinaryExpr(expr *ast.Binary) any {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+
+	switch expr.Operator.Type {
+	case token.PLUS:
+		c.emit(OP_ADD, nil)
+	case token.MINUS:
+		c.emit(OP_SUBTRACT, nil)
+	case token.STAR:
+		c.emit(OP_MULTIPLY, nil)
+	case token.SLASH:
+		c.emit(OP_DIVIDE, nil)
+	case token.EQUAL_EQUAL:
+		c.emit(OP_EQUAL, nil)
+	case token.BANG_EQUAL:
+		c.emit(OP_EQUAL, nil)
+		c.emit(OP_NOT, nil)
+	case token.GREATER:
+		c.emit(OP_GREATER, nil)
+	case token.GREATER_EQUAL:
+		c.emit(OP_LESS, nil)
+		c.emit(OP_NOT, nil)
+	case token.LESS:
+		c.emit(OP_LESS, nil)
+	case token.LESS_EQUAL:
+		c.emit(OP_GREATER, nil)
+		c.emit(OP_NOT, nil)
+	default:
+		panic(unsupported("operator '" + expr.Operator.Lexeme + "'"))
+	}
+	return nil
+}
+
+func (c *compiler) VisitCallExpr(expr *ast.Call) any {
+	panic(unsupported("calls"))
+}
+
+func (c *compiler) VisitGetExpr(expr *ast.Get) any {
+	panic(unsupported("property access"))
+}
+
+func (c *compiler) VisitListExpr(expr *ast.List) any {
+	panic(unsupported("lists"))
+}
+
+func (c *compiler) VisitSliceExpr(expr *ast.Slice) any {
+	panic(unsupported("slices"))
+}
+
+func (c *compiler) VisitRangeExpr(expr *ast.Range) any {
+	panic(unsupported("ranges"))
+}
+
+func (c *compiler) VisitSubscriptExpr(expr *ast.Subscript) any {
+	panic(unsupported("subscripts"))
+}
+
+func (c *compiler) VisitGroupingExpr(expr *ast.Grouping) any {
+	c.expr(expr.Expression)
+	return nil
+}
+
+func (c *compiler) VisitBlockExprExpr(expr *ast.BlockExpr) any {
+	panic(unsupported("block expressions"))
+}
+
+func (c *compiler) VisitLambdaExpr(expr *ast.Lambda) any {
+	panic(unsupported("lambdas"))
+}
+
+func (c *compiler) VisitLiteralExpr(expr *ast.Literal) any {
+	switch expr.Value {
+	case nil:
+		c.emit(OP_NIL, nil)
+	case true:
+		c.emit(OP_TRUE, nil)
+	case false:
+		c.emit(OP_FALSE, nil)
+	default:
+		c.emit(OP_CONSTANT, expr.Value)
+	}
+	return nil
+}
+
+// like clox, OP_JUMP_IF_FALSE leaves the condition on the stack, so the short-circuited
+// operand is the result and the other branch pops it first
+func (c *compiler) VisitLogicalExpr(expr *ast.Logical) any {
+	c.expr(expr.Left)
+
+	if expr.Operator.Type == token.AND {
+		endJump := c.emit(OP_JUMP_IF_FALSE, nil)
+		c.emit(OP_POP, nil)
+		c.expr(expr.Right)
+		c.patchJump(endJump)
+		return nil
+	}
+
+	elseJump := c.emit(OP_JUMP_IF_FALSE, nil)
+	endJump := c.emit(OP_JUMP, nil)
+	c.patchJump(elseJump)
+	c.emit(OP_POP, nil)
+	c.expr(expr.Right)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *compiler) VisitSetExpr(expr *ast.Set) any {
+	panic(unsupported("property assignment"))
+}
+
+func (c *compiler) VisitSuperExpr(expr *ast.Super) any {
+	panic(unsupported("super"))
+}
+
+func (c *compiler) VisitThisExpr(expr *ast.This) any {
+	panic(unsupported("this"))
+}
+
+func (c *compiler) VisitUnaryExpr(expr *ast.Unary) any {
+	c.expr(expr.Right)
+
+	switch expr.Operator.Type {
+	case token.MINUS:
+		c.emit(OP_NEGATE, nil)
+	case token.BANG:
+		c.emit(OP_NOT, nil)
+	default:
+		panic(unsupported("operator '" + expr.Operator.Lexeme + "'"))
+	}
+	return nil
+}
+
+func (c *compiler) VisitVariableExpr(expr *ast.Variable) any {
+	c.emit(OP_GET_GLOBAL, expr.Name.Lexeme)
+	return nil
+}
@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, code string) []ast.Stmt {
+	scan := scanner.New(code)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+
+	parser := parser.New(tokens)
+	return parser.Parse()
+}
+
+func compile(t *testing.T, code string) []Instr {
+	instrs, err := Compile(parse(t, code))
+	if err != nil {
+		t.Fatalf("failed to compile: %v", err)
+	}
+	return instrs
+}
+
+func TestCompilePrint(t *testing.T) {
+	assert.Equal(t, []Instr{
+		{Op: OP_CONSTANT, Operand: 1.0},
+		{Op: OP_CONSTANT, Operand: 2.0},
+		{Op: OP_ADD},
+		{Op: OP_PRINT},
+	}, compile(t, "print 1 + 2;"))
+}
+
+func TestCompileExpressions(t *testing.T) {
+	assert.Equal(t, []Instr{
+		{Op: OP_CONSTANT, Operand: 1.0},
+		{Op: OP_NEGATE},
+		{Op: OP_CONSTANT, Operand: 2.0},
+		{Op: OP_CONSTANT, Operand: 3.0},
+		{Op: OP_SUBTRACT},
+		{Op: OP_MULTIPLY},
+		{Op: OP_POP},
+		{Op: OP_CONSTANT, Operand: "a"},
+		{Op: OP_GET_GLOBAL, Operand: "b"},
+		{Op: OP_LESS},
+		{Op: OP_NOT},
+		{Op: OP_NIL},
+		{Op: OP_EQUAL},
+		{Op: OP_NOT},
+		{Op: OP_PRINT},
+	}, compile(t, `-1 * (2 - 3); print "a" >= b != nil;`))
+}
+
+func TestCompileLogical(t *testing.T) {
+	assert.Equal(t, []Instr{
+		{Op: OP_TRUE},
+		{Op: OP_JUMP_IF_FALSE, Operand: 4},
+		{Op: OP_POP},
+		{Op: OP_FALSE},
+		{Op: OP_JUMP_IF_FALSE, Operand: 6},
+		{Op: OP_JUMP, Operand: 8},
+		{Op: OP_POP},
+		{Op: OP_NIL},
+		{Op: OP_PRINT},
+	}, compile(t, "print true and false or nil;"))
+}
+
+func TestCompileUnsupported(t *testing.T) {
+	_, err := Compile(parse(t, "var a = 1;"))
+	assert.EqualError(t, err, "Can't compile variable declarations yet.")
+}
+
+func TestDisassemble(t *testing.T) {
+	assert.Equal(t, `0000 OP_CONSTANT 1
+0001 OP_CONSTANT 2
+0002 OP_ADD
+0003 OP_PRINT
+0004 OP_CONSTANT "a"
+0005 OP_JUMP_IF_FALSE -> 0008
+0006 OP_POP
+0007 OP_GET_GLOBAL 'b'
+0008 OP_POP
+`, Disassemble(compile(t, `print 1 + 2; "a" and b;`)))
+}
@@ -2,29 +2,193 @@ package globals
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
+type StackFrame struct {
+	Function string
+	Line     int
+	// File is the source file a #line directive set for the line, if any
+	File string
+}
+
 type RuntimeError struct {
 	Token   token.Token
 	Message string
+	// StackTrace lists the calls active when the error was raised, innermost first
+	StackTrace []StackFrame
+}
+
+func (e RuntimeError) Error() string {
+	return fmt.Sprintf("%s\n[%s]", e.Message, Location(e.Token.File, e.Token.Line))
+}
+
+// Location describes a line in a report, as "line N", or "file:N" when a #line directive
+// set the file the line comes from.
+func Location(file string, line int) string {
+	if file == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s:%d", file, line)
 }
 
 var HadError bool
 var HadRuntimeError bool
 
-var ReportError = func(line int, where string, message string) {
-	fmt.Fprintln(os.Stderr, fmt.Sprintf("[line %d] Error%s: %s", line, where, message))
-	HadError = true
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "Warning"
+	}
+	return "Error"
 }
 
-var ReportErrorAt = func(tok token.Token, message string) {
-	ReportError(tok.Line, fmt.Sprintf(" at '%s'", tok.Lexeme), message)
+// WarningsAsErrors makes reported warnings set HadError, failing the run like errors do.
+var WarningsAsErrors bool
+
+var Report = report
+
+func report(severity Severity, file string, line int, where string, message string) {
+	fmt.Fprintln(os.Stderr, formatReport(severity, file, line, where, message))
+	setHadError(severity)
+}
+
+func formatReport(severity Severity, file string, line int, where string, message string) string {
+	return fmt.Sprintf("[%s] %s%s: %s", Location(file, line), severity, where, message)
+}
+
+func setHadError(severity Severity) {
+	if severity == SeverityError || WarningsAsErrors {
+		HadError = true
+	}
+}
+
+var ReportError = reportError
+
+func reportError(file string, line int, where string, message string) {
+	Report(SeverityError, file, line, where, message)
+}
+
+var ReportErrorAt = reportErrorAt
+
+func reportErrorAt(tok token.Token, message string) {
+	ReportError(tok.File, tok.Line, fmt.Sprintf(" at '%s'", tok.Lexeme), message)
 }
 
-var ReportRuntimeError = func(err RuntimeError) {
-	fmt.Fprintln(os.Stderr, fmt.Sprintf("%s\n[line %d]", err.Message, err.Token.Line))
+// ReportWarning reports a problem that doesn't stop the program from running, unless
+// WarningsAsErrors is set.
+var ReportWarning = reportWarning
+
+func reportWarning(file string, line int, where string, message string) {
+	Report(SeverityWarning, file, line, where, message)
+}
+
+var ReportWarningAt = reportWarningAt
+
+func reportWarningAt(tok token.Token, message string) {
+	ReportWarning(tok.File, tok.Line, fmt.Sprintf(" at '%s'", tok.Lexeme), message)
+}
+
+// RuntimeErrorOutput is where runtime errors are reported to.
+var RuntimeErrorOutput io.Writer = os.Stderr
+
+// CollapseRepeatedRuntimeErrors makes ReportRuntimeError report a run of identical errors
+// once, followed by a "(repeated N times)" line once a different error is reported or
+// FlushRuntimeErrors is called. Whoever sets it must flush before exiting, like golox does
+// when a script or the REPL ends.
+var CollapseRepeatedRuntimeErrors bool
+
+var lastRuntimeError string
+var runtimeErrorRepeats int
+
+var ReportRuntimeError = reportRuntimeError
+
+func reportRuntimeError(err RuntimeError) {
 	HadRuntimeError = true
+
+	var report strings.Builder
+	if len(err.StackTrace) == 0 {
+		fmt.Fprintf(&report, "%s\n[%s]\n", err.Message, Location(err.Token.File, err.Token.Line))
+	} else {
+		fmt.Fprintln(&report, err.Message)
+		for _, frame := range err.StackTrace {
+			fmt.Fprintf(&report, "[%s] in %s\n", Location(frame.File, frame.Line), frame.Function)
+		}
+	}
+
+	if CollapseRepeatedRuntimeErrors {
+		if report.String() == lastRuntimeError {
+			runtimeErrorRepeats++
+			return
+		}
+		FlushRuntimeErrors()
+		lastRuntimeError = report.String()
+	}
+	io.WriteString(RuntimeErrorOutput, report.String())
+}
+
+// FlushRuntimeErrors reports how many times the last runtime error was repeated, if it was
+// held back by CollapseRepeatedRuntimeErrors.
+func FlushRuntimeErrors() {
+	if runtimeErrorRepeats == 1 {
+		fmt.Fprintln(RuntimeErrorOutput, "(repeated 1 time)")
+	} else if runtimeErrorRepeats > 1 {
+		fmt.Fprintf(RuntimeErrorOutput, "(repeated %d times)\n", runtimeErrorRepeats)
+	}
+	lastRuntimeError = ""
+	runtimeErrorRepeats = 0
+}
+
+// Reset clears the error flags and reporting options, drops any held back repeated
+// runtime error and restores the default reporters.
+func Reset() {
+	HadError = false
+	HadRuntimeError = false
+	WarningsAsErrors = false
+	CollapseRepeatedRuntimeErrors = false
+	lastRuntimeError = ""
+	runtimeErrorRepeats = 0
+	Report = report
+	ReportError = reportError
+	ReportErrorAt = reportErrorAt
+	ReportWarning = reportWarning
+	ReportWarningAt = reportWarningAt
+	ReportRuntimeError = reportRuntimeError
+}
+
+// Capture clears the error flags and replaces Report and ReportRuntimeError with reporters
+// that collect the messages instead of printing them, setting the flags as usual. It returns
+// a function that restores the reporters and the flags to how they were, and returns the
+// messages: errors and warnings formatted like they're printed, and runtime errors as
+// "[line N] message" or "[file:N] message".
+func Capture() func() []string {
+	origReport, origReportRuntimeError := Report, ReportRuntimeError
+	origHadError, origHadRuntimeError := HadError, HadRuntimeError
+	HadError, HadRuntimeError = false, false
+
+	var messages []string
+	Report = func(severity Severity, file string, line int, where string, message string) {
+		messages = append(messages, formatReport(severity, file, line, where, message))
+		setHadError(severity)
+	}
+	ReportRuntimeError = func(err RuntimeError) {
+		messages = append(messages, fmt.Sprintf("[%s] %s", Location(err.Token.File, err.Token.Line), err.Message))
+		HadRuntimeError = true
+	}
+
+	return func() []string {
+		Report, ReportRuntimeError = origReport, origReportRuntimeError
+		HadError, HadRuntimeError = origHadError, origHadRuntimeError
+		return messages
+	}
 }
@@ -0,0 +1,112 @@
+package globals
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/token"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollapseRepeatedRuntimeErrors(t *testing.T) {
+	origOutput := RuntimeErrorOutput
+	defer func() {
+		RuntimeErrorOutput = origOutput
+		Reset()
+	}()
+
+	var output strings.Builder
+	RuntimeErrorOutput = &output
+	CollapseRepeatedRuntimeErrors = true
+
+	fail := RuntimeError{Token: token.New(token.IDENTIFIER, "x", nil, 3), Message: "Boom."}
+	for i := 0; i < 4; i++ {
+		ReportRuntimeError(fail)
+	}
+	ReportRuntimeError(RuntimeError{Token: token.New(token.IDENTIFIER, "y", nil, 5), Message: "Other."})
+	ReportRuntimeError(fail)
+	ReportRuntimeError(fail)
+	FlushRuntimeErrors()
+
+	assert.Equal(t, "Boom.\n[line 3]\n(repeated 3 times)\nOther.\n[line 5]\nBoom.\n[line 3]\n(repeated 1 time)\n", output.String())
+	assert.True(t, HadRuntimeError)
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	restore := Capture()
+	defer func() {
+		restore()
+		WarningsAsErrors = false
+	}()
+
+	Report(SeverityWarning, "", 1, "", "Just a warning.")
+	assert.False(t, HadError)
+
+	WarningsAsErrors = true
+	Report(SeverityWarning, "", 1, "", "Just a warning.")
+	assert.True(t, HadError)
+
+	HadError = false
+	WarningsAsErrors = false
+	ReportError("", 1, "", "An error.")
+	assert.True(t, HadError)
+}
+
+func TestCapture(t *testing.T) {
+	HadError = true
+	restore := Capture()
+	defer func() {
+		restore()
+		Reset()
+	}()
+	assert.False(t, HadError)
+
+	ReportWarningAt(token.New(token.IDENTIFIER, "x", nil, 1), "Unused.")
+	assert.False(t, HadError)
+	ReportErrorAt(token.New(token.IDENTIFIER, "y", nil, 2), "Undefined.")
+	ReportError("", 3, " at end", "Expect ';'.")
+	ReportErrorAt(token.Token{Type: token.IDENTIFIER, Lexeme: "w", Line: 10, File: "lib.lox"}, "Undefined.")
+	ReportRuntimeError(RuntimeError{Token: token.New(token.IDENTIFIER, "z", nil, 4), Message: "Boom."})
+	assert.True(t, HadError)
+	assert.True(t, HadRuntimeError)
+
+	assert.Equal(t, []string{
+		"[line 1] Warning at 'x': Unused.",
+		"[line 2] Error at 'y': Undefined.",
+		"[line 3] Error at end: Expect ';'.",
+		"[lib.lox:10] Error at 'w': Undefined.",
+		"[line 4] Boom.",
+	}, restore())
+	assert.True(t, HadError)
+	assert.False(t, HadRuntimeError)
+}
+
+func TestReset(t *testing.T) {
+	ReportError = func(file string, line int, where string, message string) {}
+	ReportRuntimeError = func(err RuntimeError) {}
+	HadError = true
+	HadRuntimeError = true
+	WarningsAsErrors = true
+	CollapseRepeatedRuntimeErrors = true
+	lastRuntimeError = "Boom.\n[line 4]\n"
+	runtimeErrorRepeats = 2
+
+	Reset()
+	assert.False(t, HadError)
+	assert.False(t, HadRuntimeError)
+	assert.False(t, WarningsAsErrors)
+	assert.False(t, CollapseRepeatedRuntimeErrors)
+
+	// the default reporters are back, so reporting sets the flags again
+	var output strings.Builder
+	origOutput := RuntimeErrorOutput
+	RuntimeErrorOutput = &output
+	defer func() {
+		RuntimeErrorOutput = origOutput
+		Reset()
+	}()
+	ReportRuntimeError(RuntimeError{Token: token.New(token.IDENTIFIER, "z", nil, 4), Message: "Boom."})
+	FlushRuntimeErrors()
+	assert.True(t, HadRuntimeError)
+	assert.Equal(t, "Boom.\n[line 4]\n", output.String())
+}
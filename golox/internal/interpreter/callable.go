@@ -3,14 +3,21 @@ package interpreter
 import "github.com/michael-go/lox/golox/internal/ast"
 
 type LoxCallable interface {
+	// Arity is the number of arguments the callable takes, or Variadic to take any number
 	Arity() int
 	Call(interpreter *Interpreter, arguments []any) any
 }
 
+const Variadic = -1
+
 type LoxFunction struct {
 	declaration   *ast.Function
 	closure       *Environment
 	isInitializer bool
+	// isMethod is set on methods bound to an instance
+	isMethod bool
+	// name is only used for display, function expressions get the name of the variable they're assigned to
+	name string
 }
 
 func NewLoxFunction(declaration *ast.Function, closure *Environment, isInitializer bool) *LoxFunction {
@@ -18,6 +25,7 @@ func NewLoxFunction(declaration *ast.Function, closure *Environment, isInitializ
 		declaration:   declaration,
 		closure:       closure,
 		isInitializer: isInitializer,
+		name:          declaration.Name.Lexeme,
 	}
 }
 
@@ -26,40 +34,88 @@ func (f LoxFunction) Arity() int {
 }
 
 func (f LoxFunction) Call(interpreter *Interpreter, arguments []any) (ret any) {
-	environment := NewEnvironment(f.closure)
+	leaf := interpreter.leaves[f.declaration]
+	var environment *Environment
+	if leaf {
+		environment = interpreter.callEnvironment(f.closure)
+	} else {
+		environment = NewEnvironment(f.closure)
+	}
 
 	for i, param := range f.declaration.Params {
 		environment.Define(param.Lexeme, arguments[i])
 	}
 
+	// a return in a block expression can only unwind by panicking
 	defer func() {
 		if r := recover(); r != nil {
 			if err, ok := r.(Return); ok {
 				if f.isInitializer {
-					ret = f.closure.GetAt(0, "this")
+					ret = f.this()
 				} else {
 					ret = err.Value
 				}
+			} else if brk, ok := r.(Break); ok {
+				// a break can't exit a loop around the call
+				panic(brk.escaped())
 			} else {
 				panic(r)
 			}
 		}
 	}()
 
-	interpreter.executeBlock(f.declaration.Body, environment)
+	value := interpreter.executeBlock(f.declaration.Body, environment)
+	if leaf {
+		interpreter.releaseEnvironment(environment)
+	}
+	if ret, ok := value.(Return); ok {
+		if f.isInitializer {
+			return f.this()
+		}
+		return ret.Value
+	}
+	if brk, ok := value.(Break); ok {
+		panic(brk.escaped())
+	}
 	if f.isInitializer {
-		return f.closure.GetAt(0, "this")
+		return f.this()
+	}
+	if interpreter.Options.ImplicitReturn && f.endsWithExpression() {
+		return value
+	}
+	if interpreter.Options.FluentMethods && f.isMethod {
+		return f.this()
 	}
 	ret = nil
 	return
 }
 
+func (f LoxFunction) endsWithExpression() bool {
+	body := f.declaration.Body
+	if len(body) == 0 {
+		return false
+	}
+	_, ok := body[len(body)-1].(*ast.Expression)
+	return ok
+}
+
 func (f LoxFunction) String() string {
-	return "<fn " + f.declaration.Name.Lexeme + ">"
+	if f.name == "" {
+		return "<fn>"
+	}
+	return "<fn " + f.name + ">"
+}
+
+// this returns the instance a method is bound to, the only variable of its closure
+func (f LoxFunction) this() any {
+	return f.closure.GetAt(0, 0)
 }
 
 func (f LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
 	environment := NewEnvironment(f.closure)
 	environment.Define("this", instance)
-	return NewLoxFunction(f.declaration, environment, f.isInitializer)
+	bound := NewLoxFunction(f.declaration, environment, f.isInitializer)
+	bound.name = f.name
+	bound.isMethod = true
+	return bound
 }
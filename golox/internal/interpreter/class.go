@@ -13,6 +13,8 @@ type LoxClass struct {
 	name       string
 	superclass ILoxClass
 	methods    map[string]*LoxFunction
+	// inherited caches the methods found up the superclass chain, or nil if there's none
+	inherited map[string]*LoxFunction
 }
 
 type LoxInstance struct {
@@ -25,11 +27,12 @@ func NewLoxClass(name string, superclass ILoxClass, methods map[string]*LoxFunct
 		name:       name,
 		superclass: superclass,
 		methods:    methods,
+		inherited:  make(map[string]*LoxFunction),
 	}
 }
 
 func (c *LoxClass) String() string {
-	return c.name
+	return "<class " + c.name + ">"
 }
 
 func (c *LoxClass) Arity() int {
@@ -52,15 +55,20 @@ func (i *LoxClass) FindMethod(name string) *LoxFunction {
 	if method, ok := i.methods[name]; ok {
 		return method
 	}
+	// methods can't change at runtime, so the chain only needs to be walked once per name
+	if method, ok := i.inherited[name]; ok {
+		return method
+	}
 
+	var method *LoxFunction
 	// TODO: this is quite hacky, kinda makes the interface not used as intended
 	//  but was a way to detect interface wrapping a nil value
 	//  for related discussion see: https://stackoverflow.com/questions/13476349/check-for-nil-and-nil-interface-in-go
 	if super, ok := i.superclass.(*LoxClass); ok && super != nil {
-		return super.FindMethod(name)
+		method = super.FindMethod(name)
 	}
-
-	return nil
+	i.inherited[name] = method
+	return method
 }
 
 func NewLoxInstance(class *LoxClass) *LoxInstance {
@@ -0,0 +1,150 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type LoxList struct {
+	elements []any
+}
+
+func NewLoxList(elements []any) *LoxList {
+	return &LoxList{elements: elements}
+}
+
+func (l *LoxList) String() string {
+	return stringifyVisiting(l, make(map[any]bool), stringify)
+}
+
+// LoxRange is a lazy sequence of integers, from start by step up or down to end, which
+// it stops before. A for-in loop counts through it without building a list.
+type LoxRange struct {
+	start int64
+	end   int64
+	step  int64
+}
+
+// before reports whether n comes before the range's end
+func (r *LoxRange) before(n int64) bool {
+	if r.step > 0 {
+		return n < r.end
+	}
+	return n > r.end
+}
+
+func (r *LoxRange) String() string {
+	return fmt.Sprintf("<range %d..%d by %d>", r.start, r.end, r.step)
+}
+
+// LoxMap lists its keys sorted by their printed form, or in insertion order if it's ordered.
+type LoxMap struct {
+	entries map[any]any
+	ordered bool
+	// keys holds the keys in insertion order, only kept for an ordered map
+	keys []any
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{entries: make(map[any]any)}
+}
+
+func NewOrderedLoxMap() *LoxMap {
+	return &LoxMap{entries: make(map[any]any), ordered: true}
+}
+
+func (i *Interpreter) newMap() *LoxMap {
+	if i.Options.OrderedMaps {
+		return NewOrderedLoxMap()
+	}
+	return NewLoxMap()
+}
+
+func (m *LoxMap) Get(key any) (any, bool) {
+	value, ok := m.entries[key]
+	return value, ok
+}
+
+func (m *LoxMap) Set(key any, value any) {
+	if _, ok := m.entries[key]; !ok && m.ordered {
+		m.keys = append(m.keys, key)
+	}
+	m.entries[key] = value
+}
+
+func (m *LoxMap) Delete(key any) {
+	if _, ok := m.entries[key]; !ok {
+		return
+	}
+	delete(m.entries, key)
+	if m.ordered {
+		for i, k := range m.keys {
+			if k == key {
+				m.keys = append(m.keys[:i], m.keys[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (m *LoxMap) Keys() []any {
+	keys := make([]any, 0, len(m.entries))
+	if m.ordered {
+		return append(keys, m.keys...)
+	}
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return stringify(keys[i]) < stringify(keys[j])
+	})
+	return keys
+}
+
+func (m *LoxMap) String() string {
+	return stringifyVisiting(m, make(map[any]bool), stringify)
+}
+
+// stringifyVisiting formats collections element by element, quoting strings and handing
+// any other element to format. visiting holds the collections being formatted further up
+// the recursion, so a collection containing itself is shown as [...] or {...} instead of
+// recursing forever.
+func stringifyVisiting(obj any, visiting map[any]bool, format func(any) string) string {
+	switch v := obj.(type) {
+	case *LoxList:
+		if visiting[v] {
+			return "[...]"
+		}
+		visiting[v] = true
+		defer delete(visiting, v)
+
+		strs := make([]string, len(v.elements))
+		for i, element := range v.elements {
+			strs[i] = stringifyElement(element, visiting, format)
+		}
+		return "[" + strings.Join(strs, ", ") + "]"
+	case *LoxMap:
+		if visiting[v] {
+			return "{...}"
+		}
+		visiting[v] = true
+		defer delete(visiting, v)
+
+		keys := v.Keys()
+		strs := make([]string, len(keys))
+		for i, key := range keys {
+			strs[i] = stringifyElement(key, visiting, format) + ": " + stringifyElement(v.entries[key], visiting, format)
+		}
+		return "{" + strings.Join(strs, ", ") + "}"
+	}
+	return format(obj)
+}
+
+func stringifyElement(element any, visiting map[any]bool, format func(any) string) string {
+	if str, ok := element.(string); ok {
+		return strconv.Quote(str)
+	}
+	return stringifyVisiting(element, visiting, format)
+}
@@ -5,29 +5,115 @@ import (
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
+// Environment holds the variables of a scope. The globals are looked up by name, while
+// local variables are kept in slots, in the order they're defined, which is the order
+// the resolver numbers them by.
 type Environment struct {
+	// values holds the globals, and locals defined by name at runtime, see LoxScope
 	values    map[string]any
+	slots     []any
+	names     []string
 	enclosing *Environment
+	// captured is set once the environment is referenced by a value, so it can't be reused
+	captured bool
+	// constants are the names defined with DefineConst, which can't be assigned
+	constants map[string]bool
 }
 
 func NewEnvironment(enclosing *Environment) *Environment {
-	return &Environment{
-		values:    make(map[string]any),
-		enclosing: enclosing,
+	env := &Environment{enclosing: enclosing}
+	if enclosing == nil {
+		env.values = make(map[string]any)
 	}
+	return env
+}
+
+// clone returns a sibling environment holding copies of the values
+func (e *Environment) clone() *Environment {
+	env := NewEnvironment(e.enclosing)
+	env.slots = append([]any(nil), e.slots...)
+	env.names = append([]string(nil), e.names...)
+	for name, value := range e.values {
+		env.set(name, value)
+	}
+	return env
+}
+
+// reset empties the environment to be reused
+func (e *Environment) reset(enclosing *Environment) {
+	for i := range e.slots {
+		e.slots[i] = nil
+	}
+	e.slots = e.slots[:0]
+	e.names = e.names[:0]
+	e.values = nil
+	e.enclosing = enclosing
 }
 
 func (e *Environment) Define(name string, value any) {
+	if e.enclosing == nil {
+		e.values[name] = value
+		// like a native function, a constant can be redeclared, making it a plain variable
+		delete(e.constants, name)
+		return
+	}
+	e.slots = append(e.slots, value)
+	e.names = append(e.names, name)
+}
+
+// DefineConst defines a variable that can't be assigned, though it can be shadowed or
+// redeclared
+func (e *Environment) DefineConst(name string, value any) {
+	e.Define(name, value)
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name] = true
+}
+
+func (e *Environment) slot(name string) int {
+	for i, slotName := range e.names {
+		if slotName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// get returns the value of a variable defined in this scope
+func (e *Environment) get(name string) (any, bool) {
+	if i := e.slot(name); i >= 0 {
+		return e.slots[i], true
+	}
+	value, ok := e.values[name]
+	return value, ok
+}
+
+// set assigns a variable in this scope, defining it if it doesn't exist
+func (e *Environment) set(name string, value any) {
+	if i := e.slot(name); i >= 0 {
+		e.slots[i] = value
+		return
+	}
+	if e.values == nil {
+		e.values = make(map[string]any)
+	}
 	e.values[name] = value
 }
 
-func (e *Environment) Get(name token.Token) any {
-	if value, ok := e.values[name.Lexeme]; ok {
-		return value
+// variables returns the names of the variables defined in this scope
+func (e *Environment) variables() []string {
+	names := append([]string(nil), e.names...)
+	for name := range e.values {
+		names = append(names, name)
 	}
+	return names
+}
 
-	if e.enclosing != nil {
-		return e.enclosing.Get(name)
+func (e *Environment) Get(name token.Token) any {
+	if env := e.lookup(name.Lexeme); env != nil {
+		value, _ := env.get(name.Lexeme)
+		return value
 	}
 
 	panic(globals.RuntimeError{
@@ -36,8 +122,18 @@ func (e *Environment) Get(name token.Token) any {
 	})
 }
 
-func (e *Environment) GetAt(distance int, name string) any {
-	return e.ancestor(distance).values[name]
+// lookup returns the innermost environment defining name, or nil
+func (e *Environment) lookup(name string) *Environment {
+	for env := e; env != nil; env = env.enclosing {
+		if _, ok := env.get(name); ok {
+			return env
+		}
+	}
+	return nil
+}
+
+func (e *Environment) GetAt(distance int, slot int) any {
+	return e.ancestor(distance).slots[slot]
 }
 
 func (e *Environment) ancestor(distance int) *Environment {
@@ -49,13 +145,14 @@ func (e *Environment) ancestor(distance int) *Environment {
 }
 
 func (e *Environment) Assign(name token.Token, value any) {
-	if _, ok := e.values[name.Lexeme]; ok {
-		e.values[name.Lexeme] = value
-		return
-	}
-
-	if e.enclosing != nil {
-		e.enclosing.Assign(name, value)
+	if env := e.lookup(name.Lexeme); env != nil {
+		if env.constants[name.Lexeme] {
+			panic(globals.RuntimeError{
+				Token:   name,
+				Message: "Can't assign to constant '" + name.Lexeme + "'.",
+			})
+		}
+		env.set(name.Lexeme, value)
 		return
 	}
 
@@ -65,6 +162,6 @@ func (e *Environment) Assign(name token.Token, value any) {
 	})
 }
 
-func (e *Environment) AssignAt(distance int, name token.Token, value any) {
-	e.ancestor(distance).values[name.Lexeme] = value
+func (e *Environment) AssignAt(distance int, slot int, value any) {
+	e.ancestor(distance).slots[slot] = value
 }
@@ -2,58 +2,250 @@ package interpreter
 
 import (
 	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/michael-go/lox/golox/internal/ast"
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
+// Version is the version of the interpreter, returned to scripts by the version native
+const Version = "0.1.0"
+
+type Falsiness int
+
+const (
+	// LoxFalsiness treats only nil and false as falsey
+	LoxFalsiness Falsiness = iota
+	// JSFalsiness also treats 0, NaN, "" and empty lists and maps as falsey
+	JSFalsiness
+)
+
+type Options struct {
+	// ImplicitReturn makes a function whose last statement is an expression return its value
+	ImplicitReturn bool
+	// StrictEquality makes comparing values of different types with == or != a runtime error,
+	// except for comparing with nil
+	StrictEquality bool
+	Falsiness      Falsiness
+	// FluentMethods makes a method that ends without a return statement return `this`
+	FluentMethods bool
+	// ThousandsSep, if set, groups the digits of printed integral numbers, e.g. 1,000,000
+	ThousandsSep rune
+	// OrderedMaps makes maps list their keys in insertion order rather than sorted
+	OrderedMaps bool
+	// StrictMethodAccess makes getting a method without calling it a runtime error
+	StrictMethodAccess bool
+	// PrintSep is printed between the values of a print statement, and PrintEnd after them,
+	// by default a space and a newline
+	PrintSep string
+	PrintEnd string
+}
+
 type Interpreter struct {
+	Options Options
+
 	Globals     *Environment
-	Locals      map[ast.Expr]int
+	Locals      map[ast.Expr]Local
 	environment *Environment
+	callStack   []callFrame
+	// natives are the native functions by name, to restore the ones shadowed by a global
+	natives map[string]LoxCallable
+	// leaves are the functions whose call environments can be reused, see ResolveLeaf
+	leaves map[*ast.Function]bool
+	// freeEnvironments are released call environments, ready for reuse
+	freeEnvironments []*Environment
 
 	// declare like this to be able to mock it in tests
 	Print func(str string)
+	// PrintErr receives the output of eprint statements
+	PrintErr func(str string)
+	Now      func() time.Time
+
+	NewNumber func(value float64) Number
 }
 
+// the interpreter evaluates every kind of node the ast package generates
+var _ ast.Visitor = (*Interpreter)(nil)
+
+// Return and Break are the results of statements that stop running the enclosing
+// function or loop, which every statement containing them passes on until it's reached.
 type Return struct {
 	Value any
 }
 
+type Break struct {
+	Keyword token.Token
+}
+
+// escaped is the error for a break that reached a function or the top level without
+// finding its loop, which the resolver normally rejects
+func (b Break) escaped() globals.RuntimeError {
+	return globals.RuntimeError{Token: b.Keyword, Message: "Can't use 'break' outside of a loop."}
+}
+
+func unwinding(result any) bool {
+	switch result.(type) {
+	case Return, Break:
+		return true
+	}
+	return false
+}
+
+// Local is where a resolved local variable is: the number of scopes up from the
+// expression using it, and its slot in that scope.
+type Local struct {
+	Depth int
+	Slot  int
+}
+
+type callFrame struct {
+	function LoxCallable
+	paren    token.Token
+}
+
+// NamedNative is a native function registered under a name, which it's printed with.
+type NamedNative struct {
+	LoxCallable
+	Name string
+}
+
+func (n NamedNative) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+func defineNative(env *Environment, name string, function LoxCallable) {
+	env.Define(name, NamedNative{LoxCallable: function, Name: name})
+}
+
 func New() Interpreter {
 	globalEnv := NewEnvironment(nil)
-	globalEnv.Define("clock", ClockFunc{})
+	natives := map[string]LoxCallable{
+		"assertEq":     AssertEqFunc{},
+		"bind":         BindFunc{},
+		"chr":          ChrFunc{},
+		"clock":        ClockFunc{},
+		"currentScope": CurrentScopeFunc{},
+		"debugScope":   DebugScopeFunc{},
+		"features":     FeaturesFunc{},
+		"fields":       FieldsFunc{},
+		"format":       FormatFunc{},
+		"fromJSON":     FromJSONFunc{},
+		"getField":     GetFieldFunc{},
+		"hex":          HexFunc{},
+		"mapRemove":    MapRemoveFunc{},
+		"mapSet":       MapSetFunc{},
+		"max":          MaxFunc{},
+		"min":          MinFunc{},
+		"ord":          OrdFunc{},
+		"range":        RangeFunc{},
+		"setField":     SetFieldFunc{},
+		"toJSON":       ToJSONFunc{},
+		"unshadow":     UnshadowFunc{},
+		"version":      VersionFunc{},
+	}
+	for name, function := range natives {
+		defineNative(globalEnv, name, function)
+	}
+	globalEnv.DefineConst("PI", math.Pi)
+	globalEnv.DefineConst("E", math.E)
+	globalEnv.DefineConst("TAU", 2*math.Pi)
 	return Interpreter{
+		Options: Options{
+			PrintSep: " ",
+			PrintEnd: "\n",
+		},
 		Globals:     globalEnv,
-		Locals:      make(map[ast.Expr]int),
+		Locals:      make(map[ast.Expr]Local),
 		environment: globalEnv,
+		leaves:      make(map[*ast.Function]bool),
+		natives:     natives,
 		Print: func(str string) {
 			fmt.Print(str)
 		},
+		PrintErr: func(str string) {
+			fmt.Fprint(os.Stderr, str)
+		},
+		Now: time.Now,
+		NewNumber: func(value float64) Number {
+			return Float(value)
+		},
 	}
 }
 
 func (i *Interpreter) Interpret(statements []ast.Stmt) string {
+	value, err := i.Run(statements)
+	if err != nil {
+		globals.ReportRuntimeError(err.(globals.RuntimeError))
+		return ""
+	}
+	return i.stringify(value)
+}
+
+// Run executes the statements and returns the value of the last one, or of a top-level
+// return statement. Unlike Interpret, a runtime error is returned rather than reported.
+func (i *Interpreter) Run(statements []ast.Stmt) (value any, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			if err, ok := r.(globals.RuntimeError); ok {
-				globals.ReportRuntimeError(err)
-			} else {
+			switch r := r.(type) {
+			case Return:
+				value = r.Value
+			case Break:
+				value, err = nil, r.escaped()
+			case globals.RuntimeError:
+				value, err = nil, r
+			default:
 				panic(r)
 			}
 		}
 	}()
 
-	var value any
 	for _, statement := range statements {
 		value = i.execute(statement)
+		switch result := value.(type) {
+		case Return:
+			return result.Value, nil
+		case Break:
+			return nil, result.escaped()
+		}
 	}
-	return stringify(value)
+	return value, nil
 }
 
-func (i *Interpreter) Resolve(expr ast.Expr, depth int) {
-	i.Locals[expr] = depth
+func (i *Interpreter) Resolve(expr ast.Expr, depth int, slot int) {
+	i.Locals[expr] = Local{Depth: depth, Slot: slot}
+}
+
+// ResolveLeaf marks a function that creates no closures, so its calls reuse environments.
+func (i *Interpreter) ResolveLeaf(function *ast.Function) {
+	i.leaves[function] = true
+}
+
+// callEnvironment returns an environment for a call, reusing a released one if possible
+func (i *Interpreter) callEnvironment(enclosing *Environment) *Environment {
+	if n := len(i.freeEnvironments); n > 0 {
+		env := i.freeEnvironments[n-1]
+		i.freeEnvironments = i.freeEnvironments[:n-1]
+		env.reset(enclosing)
+		return env
+	}
+	return NewEnvironment(enclosing)
+}
+
+// releaseEnvironment makes the environment of a finished call to a leaf function available
+// for reuse, unless it was exposed with currentScope()
+func (i *Interpreter) releaseEnvironment(env *Environment) {
+	if env.captured {
+		return
+	}
+	env.reset(nil)
+	i.freeEnvironments = append(i.freeEnvironments, env)
 }
 
 func (i *Interpreter) execute(stmt ast.Stmt) any {
@@ -64,9 +256,53 @@ func stringify(obj any) string {
 	if obj == nil {
 		return "nil"
 	}
+	if number, ok := obj.(float64); ok {
+		switch {
+		case math.IsNaN(number):
+			return "NaN"
+		case math.IsInf(number, 1):
+			return "Infinity"
+		case math.IsInf(number, -1):
+			return "-Infinity"
+		case number == 0:
+			// also covers -0
+			return "0"
+		}
+	}
 	return fmt.Sprintf("%v", obj)
 }
 
+// stringify formats a printed value, applying the formatting options
+func (i *Interpreter) stringify(obj any) string {
+	switch obj.(type) {
+	case *LoxList, *LoxMap:
+		return stringifyVisiting(obj, make(map[any]bool), i.stringify)
+	}
+	if number, ok := obj.(float64); ok && i.Options.ThousandsSep != 0 {
+		// huge numbers still get an exponent
+		if number == math.Trunc(number) && math.Abs(number) < 1e21 && number != 0 {
+			return groupThousands(strconv.FormatFloat(number, 'f', -1, 64), i.Options.ThousandsSep)
+		}
+	}
+	return stringify(obj)
+}
+
+func groupThousands(digits string, sep rune) string {
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+
+	var grouped strings.Builder
+	for idx, digit := range digits {
+		if idx > 0 && (len(digits)-idx)%3 == 0 {
+			grouped.WriteRune(sep)
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String()
+}
+
 func (i *Interpreter) VisitLiteralExpr(expr *ast.Literal) any {
 	return expr.Value
 }
@@ -84,10 +320,11 @@ func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) any {
 
 	switch expr.Operator.Type {
 	case token.MINUS:
-		checkNumberOperand(expr.Operator, right)
-		return -right.(float64)
+		return fromNumber(i.checkNumberOperand(expr.Operator, right).Neg())
 	case token.BANG:
-		return !isTruthy(right)
+		return !i.isTruthy(right)
+	case token.TYPEOF:
+		return typeName(right)
 	}
 
 	return nil
@@ -100,18 +337,24 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) any {
 
 	switch op.Type {
 	case token.MINUS:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) - right.(float64)
+		l, r := i.checkNumberOperands(expr.Operator, left, right)
+		return fromNumber(l.Sub(r))
 	case token.SLASH:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) / right.(float64)
+		l, r := i.checkNumberOperands(expr.Operator, left, right)
+		return fromNumber(l.Div(r))
+	case token.DIV:
+		l, r := i.checkNumberOperands(expr.Operator, left, right)
+		if quotient, ok := l.Div(r).(Floorer); ok {
+			return fromNumber(quotient.Floor())
+		}
+		panic(globals.RuntimeError{Token: expr.Operator, Message: "Numbers don't support 'div'."})
 	case token.STAR:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) * right.(float64)
+		l, r := i.checkNumberOperands(expr.Operator, left, right)
+		return fromNumber(l.Mul(r))
 	case token.PLUS:
-		if leftIsNumber, ok := left.(float64); ok {
-			if rightIsNumber, ok := right.(float64); ok {
-				return leftIsNumber + rightIsNumber
+		if leftIsNumber, ok := i.toNumber(left); ok {
+			if rightIsNumber, ok := i.toNumber(right); ok {
+				return fromNumber(leftIsNumber.Add(rightIsNumber))
 			}
 		}
 		if leftIsString, ok := left.(string); ok {
@@ -121,27 +364,92 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) any {
 		}
 		panic(globals.RuntimeError{Token: expr.Operator, Message: "Operands must be two numbers or two strings."})
 	case token.GREATER:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) > right.(float64)
+		c := i.compare(expr.Operator, left, right)
+		return c != Unordered && c > 0
 	case token.GREATER_EQUAL:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) >= right.(float64)
+		c := i.compare(expr.Operator, left, right)
+		return c != Unordered && c >= 0
 	case token.LESS:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) < right.(float64)
+		c := i.compare(expr.Operator, left, right)
+		return c != Unordered && c < 0
 	case token.LESS_EQUAL:
-		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) <= right.(float64)
+		c := i.compare(expr.Operator, left, right)
+		return c != Unordered && c <= 0
 	case token.BANG_EQUAL:
-		return !isEqual(left, right)
+		i.checkEqualityOperands(expr.Operator, left, right)
+		return !i.isEqual(left, right)
 	case token.EQUAL_EQUAL:
-		return isEqual(left, right)
+		i.checkEqualityOperands(expr.Operator, left, right)
+		return i.isEqual(left, right)
 	}
 
 	return nil
 }
 
+// isEqual compares numbers from a custom Number implementation through Compare,
+// as they may be mixed with plain float64 literals.
+func (i *Interpreter) isEqual(left any, right any) bool {
+	_, leftIsFloat := left.(float64)
+	_, rightIsFloat := right.(float64)
+	if !leftIsFloat || !rightIsFloat {
+		if l, ok := i.toNumber(left); ok {
+			if r, ok := i.toNumber(right); ok {
+				return l.Compare(r) == 0
+			}
+		}
+	}
+	return isEqual(left, right)
+}
+
 func isEqual(left any, right any) bool {
+	return isEqualVisiting(left, right, make(map[[2]any]bool))
+}
+
+// visited holds the pairs of collections already being compared further up the
+// recursion, so comparing cyclic structures terminates.
+func isEqualVisiting(left any, right any, visited map[[2]any]bool) bool {
+	switch l := left.(type) {
+	case *LoxList:
+		r, ok := right.(*LoxList)
+		if !ok {
+			return false
+		}
+		if l == r || visited[[2]any{l, r}] {
+			return true
+		}
+		visited[[2]any{l, r}] = true
+
+		if len(l.elements) != len(r.elements) {
+			return false
+		}
+		for i := range l.elements {
+			if !isEqualVisiting(l.elements[i], r.elements[i], visited) {
+				return false
+			}
+		}
+		return true
+	case *LoxMap:
+		r, ok := right.(*LoxMap)
+		if !ok {
+			return false
+		}
+		if l == r || visited[[2]any{l, r}] {
+			return true
+		}
+		visited[[2]any{l, r}] = true
+
+		if len(l.entries) != len(r.entries) {
+			return false
+		}
+		for key, value := range l.entries {
+			other, ok := r.entries[key]
+			if !ok || !isEqualVisiting(value, other, visited) {
+				return false
+			}
+		}
+		return true
+	}
+
 	if left == nil && right == nil {
 		return true
 	}
@@ -152,40 +460,112 @@ func isEqual(left any, right any) bool {
 	return left == right
 }
 
-func isTruthy(obj any) bool {
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64, Number:
+		return "number"
+	case string:
+		return "string"
+	case *LoxClass:
+		return "class"
+	case LoxCallable:
+		return "function"
+	case *LoxInstance:
+		return "instance"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *LoxRange:
+		return "range"
+	}
+	return "unknown"
+}
+
+func (i *Interpreter) isTruthy(obj any) bool {
 	if obj == nil {
 		return false
 	}
 	if obj, ok := obj.(bool); ok {
 		return obj
 	}
+	if i.Options.Falsiness == JSFalsiness {
+		switch v := obj.(type) {
+		case float64:
+			return v != 0 && !math.IsNaN(v)
+		case Number:
+			return v.Compare(i.NewNumber(0)) != 0
+		case string:
+			return v != ""
+		case *LoxList:
+			return len(v.elements) != 0
+		case *LoxMap:
+			return len(v.entries) != 0
+		}
+	}
 	return true
 }
 
-func checkNumberOperand(operator token.Token, operand any) {
-	if _, ok := operand.(float64); ok {
-		return
+func (i *Interpreter) checkNumberOperand(operator token.Token, operand any) Number {
+	if n, ok := i.toNumber(operand); ok {
+		return n
 	}
 	panic(globals.RuntimeError{Token: operator, Message: "Operand must be a number."})
 }
 
-func checkNumberOperands(operator token.Token, left any, right any) {
-	_, okLeft := left.(float64)
-	_, okRight := right.(float64)
+func (i *Interpreter) checkNumberOperands(operator token.Token, left any, right any) (Number, Number) {
+	l, okLeft := i.toNumber(left)
+	r, okRight := i.toNumber(right)
 	if okLeft && okRight {
-		return
+		return l, r
 	}
 	panic(globals.RuntimeError{Token: operator, Message: "Operands must be numbers."})
 }
 
+// compare orders two numbers, or two strings lexically, see Number.Compare
+func (i *Interpreter) compare(operator token.Token, left any, right any) int {
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r)
+		}
+	}
+	l, okLeft := i.toNumber(left)
+	r, okRight := i.toNumber(right)
+	if okLeft && okRight {
+		return l.Compare(r)
+	}
+	panic(globals.RuntimeError{Token: operator, Message: "Operands must be two numbers or two strings."})
+}
+
+func (i *Interpreter) checkEqualityOperands(operator token.Token, left any, right any) {
+	if !i.Options.StrictEquality || left == nil || right == nil {
+		return
+	}
+	if leftType, rightType := typeName(left), typeName(right); leftType != rightType {
+		panic(globals.RuntimeError{Token: operator, Message: fmt.Sprintf("Can't compare %s with %s.", leftType, rightType)})
+	}
+}
+
 func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) any {
-	i.evaluate(stmt.Expression)
-	return nil
+	return i.evaluate(stmt.Expression)
 }
 
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) any {
+	values := make([]string, len(stmt.Expressions))
+	for idx, expr := range stmt.Expressions {
+		values[idx] = i.stringify(i.evaluate(expr))
+	}
+	i.Print(strings.Join(values, i.Options.PrintSep) + i.Options.PrintEnd)
+	return nil
+}
+
+func (i *Interpreter) VisitEprintStmt(stmt *ast.Eprint) any {
 	value := i.evaluate(stmt.Expression)
-	i.Print(fmt.Sprintln(stringify(value)))
+	i.PrintErr(fmt.Sprintln(i.stringify(value)))
 	return nil
 }
 
@@ -193,30 +573,54 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.Var) any {
 	var value any
 	if stmt.Initializer != nil {
 		value = i.evaluate(stmt.Initializer)
+		inferFunctionName(stmt.Initializer, value, stmt.Name.Lexeme)
 	}
 
 	i.environment.Define(stmt.Name.Lexeme, value)
 	return nil
 }
 
+func (i *Interpreter) VisitDestructureStmt(stmt *ast.Destructure) any {
+	list, ok := i.evaluate(stmt.Initializer).(*LoxList)
+	if !ok {
+		panic(globals.RuntimeError{Token: stmt.Paren, Message: "Can only destructure lists."})
+	}
+	if len(list.elements) != len(stmt.Names) {
+		panic(globals.RuntimeError{Token: stmt.Paren, Message: fmt.Sprintf("Expected %d elements to destructure but got %d.", len(stmt.Names), len(list.elements))})
+	}
+
+	for idx, name := range stmt.Names {
+		i.environment.Define(name.Lexeme, list.elements[idx])
+	}
+	return nil
+}
+
+// inferFunctionName names a function expression after the variable it's assigned to.
+func inferFunctionName(expr ast.Expr, value any, name string) {
+	if _, ok := expr.(*ast.Lambda); ok {
+		value.(*LoxFunction).name = name
+	}
+}
+
 func (i *Interpreter) VisitVariableExpr(expr *ast.Variable) any {
 	return i.lookUpVariable(expr.Name, expr)
 }
 
 func (i *Interpreter) lookUpVariable(name token.Token, expr ast.Expr) any {
-	distance, ok := i.Locals[expr]
+	local, ok := i.Locals[expr]
 	if ok {
-		return i.environment.GetAt(distance, name.Lexeme)
+		return i.environment.GetAt(local.Depth, local.Slot)
 	}
 	return i.Globals.Get(name)
 }
 
 func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) any {
 	value := i.evaluate(expr.Value)
+	inferFunctionName(expr.Value, value, expr.Name.Lexeme)
 
-	distance, ok := i.Locals[expr]
+	local, ok := i.Locals[expr]
 	if ok {
-		i.environment.AssignAt(distance, expr.Name, value)
+		i.environment.AssignAt(local.Depth, local.Slot, value)
 	} else {
 		i.Globals.Assign(expr.Name, value)
 	}
@@ -224,26 +628,66 @@ func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) any {
 	return value
 }
 
+// VisitMultiAssignStmt evaluates all the values before assigning any target, so
+// `a, b = b, a;` swaps them.
+func (i *Interpreter) VisitMultiAssignStmt(stmt *ast.MultiAssign) any {
+	values := make([]any, len(stmt.Values))
+	for idx, value := range stmt.Values {
+		values[idx] = i.evaluate(value)
+	}
+
+	for idx, target := range stmt.Targets {
+		switch target := target.(type) {
+		case *ast.Variable:
+			if local, ok := i.Locals[target]; ok {
+				i.environment.AssignAt(local.Depth, local.Slot, values[idx])
+			} else {
+				i.Globals.Assign(target.Name, values[idx])
+			}
+		case *ast.Get:
+			object, ok := i.evaluate(target.Object).(*LoxInstance)
+			if !ok {
+				panic(globals.RuntimeError{Token: target.Name, Message: "Only instances have fields."})
+			}
+			object.Set(target.Name, values[idx])
+		}
+	}
+	return nil
+}
+
 func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) any {
-	i.executeBlock(stmt.Statements, NewEnvironment(i.environment))
+	if result := i.executeBlock(stmt.Statements, NewEnvironment(i.environment)); unwinding(result) {
+		return result
+	}
 	return nil
 }
 
-func (i *Interpreter) executeBlock(statements []ast.Stmt, env *Environment) {
+// executeBlock returns the value of the last statement, which is only non-nil for an expression
+// statement, or the Return or Break that stopped the block.
+func (i *Interpreter) executeBlock(statements []ast.Stmt, env *Environment) any {
 	previous := i.environment
 	defer func() { i.environment = previous }()
 	i.environment = env
 
+	var value any
 	for _, statement := range statements {
-		i.execute(statement)
+		value = i.execute(statement)
+		if unwinding(value) {
+			break
+		}
 	}
+	return value
 }
 
 func (i *Interpreter) VisitIfStmt(stmt *ast.If) any {
-	if isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.ThenBranch)
+	var result any
+	if i.isTruthy(i.evaluate(stmt.Condition)) {
+		result = i.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
-		i.execute(stmt.ElseBranch)
+		result = i.execute(stmt.ElseBranch)
+	}
+	if unwinding(result) {
+		return result
 	}
 	return nil
 }
@@ -252,11 +696,11 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) any {
 	left := i.evaluate(expr.Left)
 
 	if expr.Operator.Type == token.OR {
-		if isTruthy(left) {
+		if i.isTruthy(left) {
 			return left
 		}
 	} else {
-		if !isTruthy(left) {
+		if !i.isTruthy(left) {
 			return left
 		}
 	}
@@ -265,44 +709,259 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) any {
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *ast.While) any {
-	for isTruthy(i.evaluate(stmt.Condition)) {
-		i.execute(stmt.Body)
+	result, completed := i.loop(stmt)
+	// the else branch only runs when the loop ends without a break, outside of the loop
+	// so a break in it exits an enclosing loop
+	if completed && stmt.ElseBranch != nil {
+		return i.execute(stmt.ElseBranch)
+	}
+	return result
+}
+
+// loop runs the while loop, completed is false if it was exited by a break or return
+func (i *Interpreter) loop(stmt *ast.While) (result any, completed bool) {
+	previous := i.environment
+	defer func() {
+		i.environment = previous
+		// a break in a block expression can only unwind by panicking
+		if r := recover(); r != nil {
+			if _, ok := r.(Break); !ok {
+				panic(r)
+			}
+			result, completed = nil, false
+		}
+	}()
+
+	for i.isTruthy(i.evaluate(stmt.Condition)) {
+		result := i.execute(stmt.Body)
+		if _, ok := result.(Break); ok {
+			return nil, false
+		} else if unwinding(result) {
+			return result, false
+		}
+		if stmt.PerIteration {
+			// the loop runs in the block declaring the loop variable, which is copied before
+			// the increment so closures from the finished iteration keep their own value
+			i.environment = i.environment.clone()
+		}
+		if stmt.Increment != nil {
+			i.evaluate(stmt.Increment)
+		}
+	}
+	return nil, true
+}
+
+func (i *Interpreter) VisitForEachStmt(stmt *ast.ForEach) (result any) {
+	defer func() {
+		// a break in a block expression can only unwind by panicking
+		if r := recover(); r != nil {
+			if _, ok := r.(Break); !ok {
+				panic(r)
+			}
+			result = nil
+		}
+	}()
+
+	switch iterable := i.evaluate(stmt.Iterable).(type) {
+	case *LoxRange:
+		for n := iterable.start; iterable.before(n); n += iterable.step {
+			if result, done := i.iterate(stmt, float64(n)); done {
+				return result
+			}
+		}
+	case *LoxList:
+		for idx := 0; idx < len(iterable.elements); idx++ {
+			if result, done := i.iterate(stmt, iterable.elements[idx]); done {
+				return result
+			}
+		}
+	default:
+		panic(globals.RuntimeError{Token: stmt.Keyword, Message: "Can only iterate over lists and ranges."})
 	}
 	return nil
 }
 
+// iterate runs the body of a for-in loop with a new loop variable holding value, and
+// reports if it ended the loop, returning the Return it passes on
+func (i *Interpreter) iterate(stmt *ast.ForEach, value any) (any, bool) {
+	env := NewEnvironment(i.environment)
+	env.Define(stmt.Name.Lexeme, value)
+	result := i.executeBlock([]ast.Stmt{stmt.Body}, env)
+	if _, ok := result.(Break); ok {
+		return nil, true
+	}
+	return result, unwinding(result)
+}
+
+func (i *Interpreter) VisitBreakStmt(stmt *ast.Break) any {
+	return Break{Keyword: stmt.Keyword}
+}
+
 func (i *Interpreter) VisitCallExpr(call *ast.Call) any {
-	callee := i.evaluate(call.Callee)
+	var callee any
+	if get, ok := call.Callee.(*ast.Get); ok {
+		callee = i.property(get, true)
+	} else {
+		callee = i.evaluate(call.Callee)
+	}
 
 	var args []any
-	for _, arg := range call.Arguments {
-		args = append(args, i.evaluate(arg))
+	for idx, arg := range call.Arguments {
+		value := i.evaluate(arg)
+		if !call.Spread[idx] {
+			args = append(args, value)
+			continue
+		}
+
+		list, ok := value.(*LoxList)
+		if !ok {
+			panic(globals.RuntimeError{Token: call.Paren, Message: "Can only spread lists."})
+		}
+		args = append(args, list.elements...)
+	}
+
+	// an instance whose class has a `call` method can be called like a function
+	if instance, ok := callee.(*LoxInstance); ok {
+		if method := instance.class.FindMethod("call"); method != nil {
+			callee = method.Bind(instance)
+		}
 	}
 
 	if function, ok := callee.(LoxCallable); ok {
-		if len(args) != function.Arity() {
-			panic(globals.RuntimeError{Token: call.Paren, Message: fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(args))})
+		if function.Arity() != Variadic && len(args) != function.Arity() {
+			message := fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(args))
+			if !isNative(function) {
+				message = fmt.Sprintf("%s expected %d arguments but got %d.", stringify(function), function.Arity(), len(args))
+			}
+			panic(globals.RuntimeError{Token: call.Paren, Message: message})
 		}
-		return function.Call(i, args)
+		if isNative(function) {
+			return i.callNative(function, args, call)
+		}
+		return i.call(function, args, call.Paren)
 	}
 
 	panic(globals.RuntimeError{Token: call.Paren, Message: "Can only call functions and classes."})
 }
 
+// NativeNames returns the names of the global native functions.
+func (i *Interpreter) NativeNames() []string {
+	var names []string
+	for name, value := range i.Globals.values {
+		if function, ok := value.(LoxCallable); ok && isNative(function) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isNative(function LoxCallable) bool {
+	switch function.(type) {
+	case *LoxFunction, *LoxClass:
+		return false
+	}
+	return true
+}
+
+// callNative calls a native function, turning any Go panic escaping it into a runtime
+// error, so a misbehaving native doesn't crash the interpreter
+func (i *Interpreter) callNative(function LoxCallable, args []any, call *ast.Call) any {
+	defer func() {
+		r := recover()
+		switch r.(type) {
+		case nil:
+			return
+		case globals.RuntimeError:
+			panic(r)
+		}
+		panic(globals.RuntimeError{
+			Token:      call.Paren,
+			Message:    fmt.Sprintf("Native function '%s' failed: %v", calleeName(call.Callee, function), r),
+			StackTrace: i.stackTrace(call.Paren),
+		})
+	}()
+
+	return i.call(function, args, call.Paren)
+}
+
+func calleeName(callee ast.Expr, function LoxCallable) string {
+	switch callee := callee.(type) {
+	case *ast.Variable:
+		return callee.Name.Lexeme
+	case *ast.Get:
+		return callee.Name.Lexeme
+	}
+	return stringify(function)
+}
+
+func (i *Interpreter) call(function LoxCallable, args []any, paren token.Token) any {
+	i.callStack = append(i.callStack, callFrame{function: function, paren: paren})
+	defer func() {
+		r := recover()
+		if err, ok := r.(nativeError); ok {
+			r = globals.RuntimeError{Token: paren, Message: err.message}
+		}
+		if err, ok := r.(globals.RuntimeError); ok && err.StackTrace == nil {
+			err.StackTrace = i.stackTrace(err.Token)
+			r = err
+		}
+
+		i.callStack = i.callStack[:len(i.callStack)-1]
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	return function.Call(i, args)
+}
+
+// stackTrace describes the current call stack for an error raised at errToken, each
+// frame with the line it's executing: the error's line for the innermost call, and
+// the line of the call into the next frame for the others.
+func (i *Interpreter) stackTrace(errToken token.Token) []globals.StackFrame {
+	var trace []globals.StackFrame
+	line, file := errToken.Line, errToken.File
+	for j := len(i.callStack) - 1; j >= 0; j-- {
+		trace = append(trace, globals.StackFrame{Function: stringify(i.callStack[j].function), Line: line, File: file})
+		line, file = i.callStack[j].paren.Line, i.callStack[j].paren.File
+	}
+	return append(trace, globals.StackFrame{Function: "<script>", Line: line, File: file})
+}
+
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) any {
 	function := NewLoxFunction(stmt, i.environment, false)
 	i.environment.Define(stmt.Name.Lexeme, function)
 	return nil
 }
 
+func (i *Interpreter) VisitBlockExprExpr(expr *ast.BlockExpr) any {
+	previous := i.environment
+	defer func() { i.environment = previous }()
+	i.environment = NewEnvironment(previous)
+
+	for _, statement := range expr.Statements {
+		// an expression can't pass on a return or break, so they unwind by panicking
+		if result := i.execute(statement); unwinding(result) {
+			panic(result)
+		}
+	}
+	if expr.Value == nil {
+		return nil
+	}
+	return i.evaluate(expr.Value)
+}
+
+func (i *Interpreter) VisitLambdaExpr(expr *ast.Lambda) any {
+	return NewLoxFunction(expr.Function, i.environment, false)
+}
+
 func (i *Interpreter) VisitReturnStmt(stmt *ast.Return) any {
 	var value any
 	if stmt.Value != nil {
 		value = i.evaluate(stmt.Value)
 	}
-
-	// Using panic to return from a function is quite hacky, but ...
-	panic(Return{value})
+	return Return{value}
 }
 
 func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
@@ -322,7 +981,7 @@ func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
 
 	if stmt.Superclass != nil {
 		i.environment = NewEnvironment(i.environment)
-		i.environment.Define("super", i.evaluate(stmt.Superclass))
+		i.environment.Define("super", superValue)
 	}
 
 	methods := make(map[string]*LoxFunction)
@@ -341,13 +1000,139 @@ func (i *Interpreter) VisitClassStmt(stmt *ast.Class) any {
 	return nil
 }
 
+func (i *Interpreter) VisitListExpr(expr *ast.List) any {
+	elements := make([]any, len(expr.Elements))
+	for idx, element := range expr.Elements {
+		elements[idx] = i.evaluate(element)
+	}
+	return NewLoxList(elements)
+}
+
+func (i *Interpreter) VisitRangeExpr(expr *ast.Range) any {
+	start, end := i.rangeBounds(expr)
+	return &LoxRange{start: start, end: end, step: 1}
+}
+
+// rangeBounds evaluates the bounds of a range, returning an exclusive end. A range whose
+// end is below its start is empty.
+func (i *Interpreter) rangeBounds(expr *ast.Range) (start int64, end int64) {
+	bound := func(value ast.Expr) int64 {
+		n, ok := i.evaluate(value).(float64)
+		if !ok || n != math.Trunc(n) || math.Abs(n) > maxSafeInteger {
+			panic(globals.RuntimeError{Token: expr.Operator, Message: "Range bounds must be integers."})
+		}
+		return int64(n)
+	}
+	start, end = bound(expr.Start), bound(expr.End)
+	if expr.Inclusive {
+		end++
+	}
+	return start, end
+}
+
+func (i *Interpreter) VisitSubscriptExpr(expr *ast.Subscript) any {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+
+	list, ok := object.(*LoxList)
+	if !ok {
+		panic(globals.RuntimeError{Token: expr.Bracket, Message: "Only lists can be indexed."})
+	}
+	n, ok := index.(float64)
+	if !ok || n != math.Trunc(n) {
+		panic(globals.RuntimeError{Token: expr.Bracket, Message: "Index must be an integer."})
+	}
+	// negative indices count from the end of the list
+	if n < 0 {
+		n += float64(len(list.elements))
+	}
+	if n < 0 || n >= float64(len(list.elements)) {
+		panic(globals.RuntimeError{Token: expr.Bracket, Message: "Index out of range."})
+	}
+	return list.elements[int(n)]
+}
+
+func (i *Interpreter) VisitSliceExpr(expr *ast.Slice) any {
+	object := i.evaluate(expr.Object)
+
+	var length int
+	switch object := object.(type) {
+	case *LoxList:
+		length = len(object.elements)
+	case string:
+		length = utf8.RuneCountInString(object)
+	default:
+		panic(globals.RuntimeError{Token: expr.Bracket, Message: "Only lists and strings can be sliced."})
+	}
+	start := i.sliceBound(expr.Start, expr.Bracket, 0, length)
+	end := i.sliceBound(expr.End, expr.Bracket, length, length)
+	if end < start {
+		end = start
+	}
+
+	if list, ok := object.(*LoxList); ok {
+		elements := make([]any, end-start)
+		copy(elements, list.elements[start:end])
+		return NewLoxList(elements)
+	}
+	return string([]rune(object.(string))[start:end])
+}
+
+// sliceBound evaluates a slice bound, counting negative bounds from the end and
+// clamping them into the sequence
+func (i *Interpreter) sliceBound(expr ast.Expr, bracket token.Token, omitted int, length int) int {
+	if expr == nil {
+		return omitted
+	}
+	n, ok := i.evaluate(expr).(float64)
+	if !ok || n != math.Trunc(n) {
+		panic(globals.RuntimeError{Token: bracket, Message: "Slice bounds must be integers."})
+	}
+	if n < 0 {
+		n += float64(length)
+	}
+	return int(math.Max(0, math.Min(n, float64(length))))
+}
+
 func (i *Interpreter) VisitGetExpr(expr *ast.Get) any {
+	return i.property(expr, false)
+}
+
+// property evaluates a get expression, running the method if it's a getter. A getter
+// can't be called like a method, nor, with StrictMethodAccess, a method used as a value.
+func (i *Interpreter) property(expr *ast.Get, called bool) any {
 	object := i.evaluate(expr.Object)
-	if obj, ok := object.(*LoxInstance); ok {
-		return obj.Get(expr.Name)
+	if object == nil && expr.Optional {
+		return nil
+	}
+	if _, ok := i.toNumber(object); ok && expr.Name.Lexeme == "toString" {
+		return numberToStringFunc{object}
+	}
+	obj, ok := object.(propertyHolder)
+	if !ok {
+		panic(globals.RuntimeError{Token: expr.Name, Message: "Only instances have properties."})
 	}
 
-	panic(globals.RuntimeError{Token: expr.Name, Message: "Only instances have properties."})
+	value := obj.Get(expr.Name)
+	if instance, ok := object.(*LoxInstance); ok {
+		if _, isField := instance.fields[expr.Name.Lexeme]; isField {
+			return value
+		}
+	}
+	method, ok := value.(*LoxFunction)
+	if !ok {
+		return value
+	}
+	if method.declaration.Getter {
+		if called {
+			panic(globals.RuntimeError{Token: expr.Name, Message: fmt.Sprintf("'%s' is a getter, not a method.", expr.Name.Lexeme)})
+		}
+		return i.call(method, nil, expr.Name)
+	}
+	if !called && i.Options.StrictMethodAccess {
+		panic(globals.RuntimeError{Token: expr.Name, Message: fmt.Sprintf("'%s' is a method, not a getter.", expr.Name.Lexeme)})
+	}
+	return value
 }
 
 func (i *Interpreter) VisitSetExpr(expr *ast.Set) any {
@@ -366,18 +1151,22 @@ func (i *Interpreter) VisitThisExpr(expr *ast.This) any {
 }
 
 func (i *Interpreter) VisitSuperExpr(expr *ast.Super) any {
-	distance, ok := i.Locals[expr]
+	local, ok := i.Locals[expr]
 	if !ok {
 		panic("No distance found for super expression")
 	}
 
-	super := i.environment.GetAt(distance, "super").(*LoxClass)
-	object := i.environment.GetAt(distance-1, "this").(*LoxInstance)
+	// super and this are the only variables of their scopes
+	super := i.environment.GetAt(local.Depth, 0).(*LoxClass)
+	object := i.environment.GetAt(local.Depth-1, 0).(*LoxInstance)
 
 	method := super.FindMethod(expr.Method.Lexeme)
 	if method == nil {
 		panic(globals.RuntimeError{Token: expr.Method, Message: fmt.Sprintf("Undefined property '%s'.", expr.Method.Lexeme)})
 	}
 
+	if method.declaration.Getter {
+		return i.call(method.Bind(object), nil, expr.Method)
+	}
 	return method.Bind(object)
 }
@@ -1,38 +1,81 @@
 package interpreter
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/resolver"
 	"github.com/michael-go/lox/golox/internal/scanner"
 	"github.com/stretchr/testify/assert"
 )
 
-func interpret(t *testing.T, code string) string {
+func interpret(t testing.TB, code string) string {
+	return interpretWith(t, code, nil)
+}
+
+// interpretWith lets a test adjust the interpreter (globals, hooks) before running the code.
+func interpretWith(t testing.TB, code string, setup func(interpreter *Interpreter)) string {
 	scan := scanner.New(code)
 	tokens, err := scan.ScanTokens()
 	if err != nil {
-		t.Fatalf("faied to scan tokens: %v", err)
+		t.Fatalf("failed to scan tokens: %v", err)
 		return ""
 	}
 
 	parser := parser.New(tokens)
 	statements := parser.Parse()
-	if statements == nil {
-		t.Fatalf("failed to parse")
-		return ""
+	for _, statement := range statements {
+		// a statement with a syntax error is nil
+		if statement == nil {
+			t.Fatalf("failed to parse")
+			return ""
+		}
 	}
 
 	interpreter := New()
+	resolver := resolver.New(&interpreter)
+	resolver.Resolve(statements)
+
 	var result string
 	interpreter.Print = func(str string) {
 		result = result + str
 	}
+	if setup != nil {
+		setup(&interpreter)
+	}
 	interpreter.Interpret(statements)
 	return result
 }
 
+func runtimeError(t *testing.T, code string, setup func(interpreter *Interpreter)) globals.RuntimeError {
+	restore := globals.Capture()
+	defer restore()
+
+	// keep the whole error, its token and stack trace, not just the captured message
+	var reported globals.RuntimeError
+	globals.ReportRuntimeError = func(err globals.RuntimeError) {
+		reported = err
+	}
+
+	interpretWith(t, code, setup)
+	return reported
+}
+
+func runtimeErrorMessage(t *testing.T, code string, setup func(interpreter *Interpreter)) string {
+	return runtimeError(t, code, setup).Message
+}
+
+func TestEmptyProgram(t *testing.T) {
+	assert.Equal(t, "", interpret(t, ""))
+	assert.Equal(t, "", interpret(t, "  \n\t// just a comment\n\n"))
+}
+
 func TestCalc(t *testing.T) {
 	result := interpret(t, `print 1 + 2 * 3;`)
 	assert.Equal(t, "7\n", result)
@@ -64,10 +107,8 @@ func TestComp(t *testing.T) {
 }
 
 func TestRuntimeError(t *testing.T) {
-	defer func() {
-		globals.HadRuntimeError = false
-	}()
-	globals.HadRuntimeError = false
+	restore := globals.Capture()
+	defer restore()
 
 	result := interpret(t, `-"foo";`)
 	assert.Equal(t, "", result)
@@ -75,17 +116,1489 @@ func TestRuntimeError(t *testing.T) {
 }
 
 func TestRuntimeErrorMessage(t *testing.T) {
-	origReportRuntimeError := globals.ReportRuntimeError
-	defer func() {
-		globals.ReportRuntimeError = origReportRuntimeError
-	}()
+	restore := globals.Capture()
+	defer restore()
 
-	errorReported := false
-	globals.ReportRuntimeError = func(err globals.RuntimeError) {
-		errorReported = true
-		assert.Equal(t, "Operands must be two numbers or two strings.", err.Message)
+	interpret(t, `print 1 + "foo";`)
+	assert.Equal(t, []string{"[line 1] Operands must be two numbers or two strings."}, restore())
+}
+
+func TestDebugScope(t *testing.T) {
+	result := interpret(t, `
+		var g = "global";
+		var x = 1;
+		{
+			var l = "local";
+			var x = 2;
+			debugScope();
+		}`)
+	assert.Contains(t, result, "l = local\n")
+	assert.Contains(t, result, "g = global\n")
+	assert.Contains(t, result, "x = 2\n")
+	assert.NotContains(t, result, "x = 1\n")
+}
+
+func TestNativeNames(t *testing.T) {
+	interpreter := New()
+	interpreter.Globals.Define("answer", 42.0)
+	names := interpreter.NativeNames()
+	assert.Contains(t, names, "clock")
+	assert.Contains(t, names, "max")
+	assert.NotContains(t, names, "answer")
+}
+
+func TestClock(t *testing.T) {
+	result := interpretWith(t, `print clock();`, func(interpreter *Interpreter) {
+		interpreter.Now = func() time.Time {
+			return time.UnixMilli(1234567)
+		}
+	})
+	assert.Equal(t, "1234.567\n", result)
+}
+
+type panickingFunc struct{}
+
+func (panickingFunc) Arity() int {
+	return 0
+}
+
+func (panickingFunc) Call(interpreter *Interpreter, arguments []any) any {
+	panic("host bug")
+}
+
+func (panickingFunc) String() string {
+	return "<native fn>"
+}
+
+func TestPanickingNative(t *testing.T) {
+	code := `
+		fun f() {
+			explode();
+		}
+		f();
+	`
+	err := runtimeError(t, code, func(interpreter *Interpreter) {
+		interpreter.Globals.Define("explode", panickingFunc{})
+	})
+	assert.Equal(t, "Native function 'explode' failed: host bug", err.Message)
+	assert.Equal(t, 3, err.Token.Line)
+	assert.Equal(t, []globals.StackFrame{{Function: "<fn f>", Line: 3}, {Function: "<script>", Line: 5}}, err.StackTrace)
+}
+
+func newMap(entries ...any) *LoxMap {
+	m := NewLoxMap()
+	for i := 0; i < len(entries); i += 2 {
+		m.Set(entries[i], entries[i+1])
 	}
+	return m
+}
 
-	interpret(t, `print 1 + "foo";`)
-	assert.True(t, errorReported)
+func TestListEquality(t *testing.T) {
+	assert.True(t, isEqual(NewLoxList([]any{1.0, "a", nil}), NewLoxList([]any{1.0, "a", nil})))
+	assert.False(t, isEqual(NewLoxList([]any{1.0, "a"}), NewLoxList([]any{1.0, "b"})))
+	assert.False(t, isEqual(NewLoxList([]any{1.0}), NewLoxList([]any{1.0, 1.0})))
+	assert.False(t, isEqual(NewLoxList([]any{}), newMap()))
+
+	nested := func(last float64) *LoxList {
+		return NewLoxList([]any{1.0, NewLoxList([]any{2.0, newMap("k", last)})})
+	}
+	assert.True(t, isEqual(nested(3), nested(3)))
+	assert.False(t, isEqual(nested(3), nested(4)))
+}
+
+func TestMapEquality(t *testing.T) {
+	assert.True(t, isEqual(newMap("a", 1.0, 2.0, true), newMap(2.0, true, "a", 1.0)))
+	assert.False(t, isEqual(newMap("a", 1.0), newMap("a", 2.0)))
+	assert.False(t, isEqual(newMap("a", 1.0), newMap("b", 1.0)))
+	assert.False(t, isEqual(newMap("a", 1.0), newMap("a", 1.0, "b", 2.0)))
+
+	assert.True(t, isEqual(newMap("l", NewLoxList([]any{1.0})), newMap("l", NewLoxList([]any{1.0}))))
+	assert.False(t, isEqual(newMap("l", NewLoxList([]any{1.0})), newMap("l", NewLoxList([]any{2.0}))))
+}
+
+func TestMapOrder(t *testing.T) {
+	ordered := NewOrderedLoxMap()
+	ordered.Set("z", 1.0)
+	ordered.Set("a", 2.0)
+	ordered.Set(3.0, "three")
+	ordered.Set("m", 4.0)
+	ordered.Set("z", 5.0)
+	ordered.Delete("a")
+	ordered.Delete("missing")
+	assert.Equal(t, []any{"z", 3.0, "m"}, ordered.Keys())
+	assert.Equal(t, `{"z": 5, 3: "three", "m": 4}`, ordered.String())
+	ordered.Set("a", 6.0)
+	assert.Equal(t, []any{"z", 3.0, "m", "a"}, ordered.Keys())
+
+	sorted := newMap("z", 1.0, "a", 2.0, "m", 3.0)
+	sorted.Delete("m")
+	assert.Equal(t, []any{"a", "z"}, sorted.Keys())
+	assert.Equal(t, `{"a": 2, "z": 1}`, sorted.String())
+
+	assert.True(t, isEqual(ordered, newMap("z", 5.0, 3.0, "three", "m", 4.0, "a", 6.0)))
+}
+
+func TestOrderedMapsOption(t *testing.T) {
+	result := interpretWith(t, `print fromJSON("{\"b\": 1, \"a\": {\"d\": 2, \"c\": 3}}");`, func(interpreter *Interpreter) {
+		interpreter.Options.OrderedMaps = true
+	})
+	assert.Equal(t, `{"a": {"c": 3, "d": 2}, "b": 1}`+"\n", result)
+
+	code := `
+		var m = fromJSON("{}");
+		mapSet(m, "z", 1);
+		mapSet(m, "a", 2);
+		mapSet(m, 3, "three");
+		mapSet(m, "z", 4);
+		print mapRemove(m, "a");
+		print mapRemove(m, "missing");
+		mapSet(m, "a", 5);
+		print m;
+	`
+	ordered := func(interpreter *Interpreter) {
+		interpreter.Options.OrderedMaps = true
+	}
+	assert.Equal(t, "2\nnil\n"+`{"z": 4, 3: "three", "a": 5}`+"\n", interpretWith(t, code, ordered))
+	assert.Equal(t, "2\nnil\n"+`{3: "three", "a": 5, "z": 4}`+"\n", interpret(t, code))
+
+	assert.Equal(t, "Argument to 'mapSet' must be a map.", runtimeErrorMessage(t, `mapSet([], 1, 2);`, nil))
+	assert.Equal(t, "Argument to 'mapRemove' must be a map.", runtimeErrorMessage(t, `mapRemove(nil, 1);`, nil))
+}
+
+func TestCyclicEquality(t *testing.T) {
+	left := NewLoxList([]any{1.0})
+	left.elements = append(left.elements, left)
+	right := NewLoxList([]any{1.0})
+	right.elements = append(right.elements, right)
+	assert.True(t, isEqual(left, right))
+}
+
+func TestSpreadArguments(t *testing.T) {
+	defineArgs := func(elements ...any) func(interpreter *Interpreter) {
+		return func(interpreter *Interpreter) {
+			interpreter.Globals.Define("args", NewLoxList(elements))
+		}
+	}
+	code := `
+		fun sum3(a, b, c) {
+			return a + b + c;
+		}`
+
+	assert.Equal(t, "6\n", interpretWith(t, code+`print sum3(...args);`, defineArgs(1.0, 2.0, 3.0)))
+	assert.Equal(t, "111\n", interpretWith(t, code+`print sum3(100, ...args, 10);`, defineArgs(1.0)))
+	assert.Equal(t, "<fn sum3> expected 3 arguments but got 2.", runtimeErrorMessage(t, code+`sum3(...args);`, defineArgs(1.0, 2.0)))
+	assert.Equal(t, "Can only spread lists.", runtimeErrorMessage(t, code+`sum3(...1);`, nil))
+}
+
+func TestChainedPropertyAssignment(t *testing.T) {
+	code := `
+		class Point {}
+		var p = Point();
+		var q = Point();
+		p.x = p.y = q.z = 0;
+		print p.x;
+		print p.y;
+		print q.z;
+	`
+	assert.Equal(t, "0\n0\n0\n", interpret(t, code))
+}
+
+func TestFieldsReflection(t *testing.T) {
+	code := `
+		class Point {
+			init(x, y) {
+				this.y = y;
+				this.x = x;
+			}
+		}
+		var p = Point(1, 2);
+	`
+	assert.Equal(t, `["x", "y"]`+"\n", interpret(t, code+`print fields(p);`))
+	assert.Equal(t, "2\n", interpret(t, code+`print getField(p, "y");`))
+	assert.Equal(t, "3\n"+`["x", "y", "z"]`+"\n", interpret(t, code+`setField(p, "z", 3); print p.z; print fields(p);`))
+
+	assert.Equal(t, "Undefined field 'z'.", runtimeErrorMessage(t, code+`getField(p, "z");`, nil))
+	assert.Equal(t, "Argument to 'fields' must be an instance.", runtimeErrorMessage(t, `fields(1);`, nil))
+	assert.Equal(t, "Field name must be a string.", runtimeErrorMessage(t, code+`getField(p, 1);`, nil))
+}
+
+func TestJSON(t *testing.T) {
+	defineSrc := func(src string) func(interpreter *Interpreter) {
+		return func(interpreter *Interpreter) {
+			interpreter.Globals.Define("src", src)
+		}
+	}
+
+	src := `{"a":[1,2.5,{"b":null}],"c":true,"d":"str"}`
+	assert.Equal(t, src+"\n", interpretWith(t, `print toJSON(fromJSON(src));`, defineSrc(src)))
+	assert.Equal(t, `{"a": [1, 2.5, {"b": nil}], "c": true, "d": "str"}`+"\n", interpretWith(t, `print fromJSON(src);`, defineSrc(src)))
+	assert.Equal(t, "true\n", interpretWith(t, `print fromJSON(src) == fromJSON(toJSON(fromJSON(src)));`, defineSrc(src)))
+
+	code := `
+		class Point {
+			init(x, y) {
+				this.x = x;
+				this.y = y;
+			}
+			toJSON() {
+				return fromJSON("[]");
+			}
+		}
+		print toJSON(Point(1, 2));
+	`
+	assert.Equal(t, "[]\n", interpret(t, code))
+
+	assert.Equal(t, "Can't convert <fn f> to JSON.", runtimeErrorMessage(t, `fun f() {} toJSON(f);`, nil))
+	assert.Equal(t, "Can't convert Foo instance to JSON without a toJSON() method.", runtimeErrorMessage(t, `class Foo {} toJSON(Foo());`, nil))
+	assert.Contains(t, runtimeErrorMessage(t, `fromJSON("{");`, nil), "Invalid JSON: ")
+}
+
+type decimal struct {
+	rat *big.Rat
+}
+
+func newDecimal(value float64) Number {
+	rat, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	return decimal{rat}
+}
+
+func (d decimal) Add(other Number) Number {
+	return decimal{new(big.Rat).Add(d.rat, other.(decimal).rat)}
+}
+
+func (d decimal) Sub(other Number) Number {
+	return decimal{new(big.Rat).Sub(d.rat, other.(decimal).rat)}
+}
+
+func (d decimal) Mul(other Number) Number {
+	return decimal{new(big.Rat).Mul(d.rat, other.(decimal).rat)}
+}
+
+func (d decimal) Div(other Number) Number {
+	return decimal{new(big.Rat).Quo(d.rat, other.(decimal).rat)}
+}
+
+func (d decimal) Neg() Number {
+	return decimal{new(big.Rat).Neg(d.rat)}
+}
+
+func (d decimal) Compare(other Number) int {
+	return d.rat.Cmp(other.(decimal).rat)
+}
+
+func (d decimal) String() string {
+	return d.rat.RatString()
+}
+
+func TestNumbers(t *testing.T) {
+	useDecimal := func(interpreter *Interpreter) {
+		interpreter.NewNumber = newDecimal
+	}
+
+	assert.Equal(t, "false\n", interpret(t, `print 0.1 + 0.2 == 0.3;`))
+	assert.Equal(t, "0.30000000000000004\n", interpret(t, `print 0.1 + 0.2;`))
+	assert.Equal(t, "false\ntrue\n", interpret(t, `var nan = 0 / 0; print nan >= nan; print nan != nan;`))
+
+	assert.Equal(t, "true\n", interpretWith(t, `print 0.1 + 0.2 == 0.3;`, useDecimal))
+	assert.Equal(t, "3/10\n", interpretWith(t, `print 0.1 + 0.2;`, useDecimal))
+	assert.Equal(t, "1/3\n", interpretWith(t, `print 1 / 3;`, useDecimal))
+	assert.Equal(t, "true\n", interpretWith(t, `print (1 / 3) * 3 == 1;`, useDecimal))
+	assert.Equal(t, "-1/5\ntrue\n", interpretWith(t, `print -(0.3 - 0.1); print 0.1 < 0.2;`, useDecimal))
+}
+
+func TestStoredBoundMethod(t *testing.T) {
+	code := `
+		class Counter {
+			init() {
+				this.n = 0;
+			}
+			inc() {
+				this.n = this.n + 1;
+				return this.n;
+			}
+		}
+		var c = Counter();
+		var inc = c.inc;
+		print inc();
+		print inc();
+		c.n = 10;
+		print inc();
+		print c.n;
+	`
+	assert.Equal(t, "1\n2\n11\n11\n", interpret(t, code))
+}
+
+func TestBoundMethodSeesLaterFields(t *testing.T) {
+	code := `
+		class Greeter {
+			greet() {
+				return "hi " + this.name;
+			}
+		}
+		var g = Greeter();
+		var greet = g.greet;
+		g.name = "a";
+		print greet();
+		g.name = "b";
+		print greet();
+	`
+	assert.Equal(t, "hi a\nhi b\n", interpret(t, code))
+}
+
+func TestLambdas(t *testing.T) {
+	assert.Equal(t, "3\n", interpret(t, `var add = fun (a, b) { return a + b; }; print add(1, 2);`))
+	assert.Equal(t, "<fn f>\n", interpret(t, `var f = fun () {}; print f;`))
+	assert.Equal(t, "<fn g>\n", interpret(t, `var g; g = fun () {}; print g;`))
+	assert.Equal(t, "<fn>\n", interpret(t, `print fun () {};`))
+	assert.Equal(t, "<fn named>\n", interpret(t, `fun named() {} var alias = named; print alias;`))
+
+	code := `
+		fun apply(f, x) {
+			return f(x);
+		}
+		var base = 10;
+		print apply(fun (x) { return x + base; }, 5);
+	`
+	assert.Equal(t, "15\n", interpret(t, code))
+}
+
+func TestEprint(t *testing.T) {
+	var stderr string
+	stdout := interpretWith(t, `print "out"; eprint "err"; eprint 1 + 2;`, func(interpreter *Interpreter) {
+		interpreter.PrintErr = func(str string) {
+			stderr += str
+		}
+	})
+	assert.Equal(t, "out\n", stdout)
+	assert.Equal(t, "err\n3\n", stderr)
+}
+
+func TestBreak(t *testing.T) {
+	code := `
+		for (var i = 0; i < 3; i = i + 1) {
+			var j = 0;
+			while (true) {
+				if (j == i) break;
+				j = j + 1;
+			}
+			print j;
+			if (i == 1) break;
+		}
+	`
+	assert.Equal(t, "0\n1\n", interpret(t, code))
+}
+
+func TestBreakInNestedIf(t *testing.T) {
+	code := `
+		var i = 0;
+		while (true) {
+			if (i > 0) {
+				if (i == 2) {
+					break;
+				}
+			}
+			i = i + 1;
+		}
+		print i;
+	`
+	assert.Equal(t, "2\n", interpret(t, code))
+}
+
+func TestEscapedBreak(t *testing.T) {
+	// the resolver rejects these, run them anyway to check the interpreter doesn't crash
+	restore := globals.Capture()
+	defer restore()
+
+	assert.Equal(t, "Can't use 'break' outside of a loop.", runtimeErrorMessage(t, `break;`, nil))
+	assert.Equal(t, "Can't use 'break' outside of a loop.", runtimeErrorMessage(t, `var x = { break; };`, nil))
+	code := `
+		fun f() { break; }
+		fun g() { var x = { break; }; }
+		var i = 0;
+		while (i < 2) {
+			i = i + 1;
+			%s();
+		}
+	`
+	err := runtimeError(t, fmt.Sprintf(code, "f"), nil)
+	assert.Equal(t, "Can't use 'break' outside of a loop.", err.Message)
+	assert.Equal(t, 2, err.Token.Line)
+	assert.Equal(t, "Can't use 'break' outside of a loop.", runtimeErrorMessage(t, fmt.Sprintf(code, "g"), nil))
+}
+
+func TestWhileInThenBranchElse(t *testing.T) {
+	// the else belongs to the if, like before while loops could have one
+	code := `var c = false; var x = 0; if (c) while (x > 0) x = x - 1; else print "else of if";`
+	assert.Equal(t, "else of if\n", interpret(t, code))
+	code = `var c = false; if (c) if (true) while (false) {} else print "inner"; else print "outer";`
+	assert.Equal(t, "outer\n", interpret(t, code))
+	code = `if (true) { while (false) {} else print "else of while"; }`
+	assert.Equal(t, "else of while\n", interpret(t, code))
+}
+
+func TestForInRange(t *testing.T) {
+	assert.Equal(t, "0\n1\n2\n", interpret(t, `for (i in 0..3) print i;`))
+	assert.Equal(t, "0\n1\n2\n3\n", interpret(t, `for (var i in 0..=3) print i;`))
+	// a descending range is empty
+	assert.Equal(t, "done\n", interpret(t, `for (i in 3..0) print i; print "done";`))
+	assert.Equal(t, "3\n", interpret(t, `for (i in 3..=3) print i;`))
+	assert.Equal(t, "<range -2..1 by 1>\n<range 0..2 by 1>\nrange\n", interpret(t, `var n = 1; print -2..n; print 0..=n; print typeof (n..n);`))
+	// a range is lazy, however long it is
+	assert.Equal(t, "0\n1\n", interpret(t, `var r = 0..9007199254740991; for (i in r) { if (i == 2) break; print i; }`))
+	assert.Equal(t, "Range bounds must be integers.", runtimeErrorMessage(t, `for (i in 0..1.5) {}`, nil))
+	assert.Equal(t, "Range bounds must be integers.", runtimeErrorMessage(t, `print "a"..=3;`, nil))
+}
+
+func TestRangeNative(t *testing.T) {
+	code := `
+		var sum = 0;
+		for (i in range(0, 1000000)) sum = sum + i;
+		print sum == 499999500000;
+	`
+	assert.Equal(t, "true\n", interpret(t, code))
+	assert.Equal(t, "10\n7\n4\n1\n", interpret(t, `for (i in range(10, 0, -3)) print i;`))
+	assert.Equal(t, "0\n2\n", interpret(t, `for (i in range(0, 3, 2)) print i;`))
+	// the range is empty when the step goes away from the end
+	assert.Equal(t, "", interpret(t, `for (i in range(0, 3, -1)) print i;`))
+	assert.Equal(t, "range\n<range 0..3 by 1>\n", interpret(t, `var r = range(0, 3); print typeof r; print r;`))
+	assert.Equal(t, "Range step can't be zero.", runtimeErrorMessage(t, `range(0, 3, 0);`, nil))
+	assert.Equal(t, "Argument to 'range' must be an integer.", runtimeErrorMessage(t, `range(0, 0.5);`, nil))
+	assert.Equal(t, "Expected 2 or 3 arguments but got 1.", runtimeErrorMessage(t, `range(3);`, nil))
+}
+
+func TestForInList(t *testing.T) {
+	code := `
+		var first;
+		var last;
+		for (x in ["a", "b", "c", "d"]) {
+			if (x == "c") break;
+			if (first == nil) first = fun () { return x; };
+			last = fun () { return x; };
+		}
+		print first();
+		print last();
+		fun head(list) {
+			for (x in list) return x;
+		}
+		print head([7, 8]);
+	`
+	assert.Equal(t, "a\nb\n7\n", interpret(t, code))
+	assert.Equal(t, "Can only iterate over lists and ranges.", runtimeErrorMessage(t, `for (x in "abc") {}`, nil))
+}
+
+func TestWhileElse(t *testing.T) {
+	code := `
+		fun find(n) {
+			var i = 0;
+			while (i < 3) {
+				if (i == n) break;
+				i = i + 1;
+			} else {
+				print "not found";
+				return;
+			}
+			print i;
+		}
+		find(1);
+		find(5);
+		var j = 0;
+		while (j < 0) j = j + 1;
+		else print "never looped";
+	`
+	assert.Equal(t, "1\nnot found\nnever looped\n", interpret(t, code))
+}
+
+func TestStackTrace(t *testing.T) {
+	code := `fun a() { b(); }
+fun b() { c(); }
+fun c() {
+  c("too", "many");
+}
+
+a();`
+	err := runtimeError(t, code, nil)
+	assert.Equal(t, "<fn c> expected 0 arguments but got 2.", err.Message)
+	assert.Equal(t, []globals.StackFrame{
+		{Function: "<fn c>", Line: 4},
+		{Function: "<fn b>", Line: 2},
+		{Function: "<fn a>", Line: 1},
+		{Function: "<script>", Line: 7},
+	}, err.StackTrace)
+
+	assert.Nil(t, runtimeError(t, `-"foo";`, nil).StackTrace)
+}
+
+func TestLineDirectiveErrors(t *testing.T) {
+	restore := globals.Capture()
+	defer restore()
+
+	interpret(t, "#line 10 \"lib.lox\"\nprint -nil;")
+	assert.Equal(t, []string{"[lib.lox:10] Operand must be a number."}, restore())
+
+	code := "fun f() {\n#line 20 \"lib.lox\"\n  -nil;\n}\n#line 3 \"main.lox\"\nf();"
+	err := runtimeError(t, code, nil)
+	assert.Equal(t, []globals.StackFrame{
+		{Function: "<fn f>", Line: 20, File: "lib.lox"},
+		{Function: "<script>", Line: 3, File: "main.lox"},
+	}, err.StackTrace)
+	assert.Equal(t, "Operand must be a number.\n[lib.lox:20]", err.Error())
+}
+
+func TestImplicitReturn(t *testing.T) {
+	implicitReturn := func(interpreter *Interpreter) {
+		interpreter.Options.ImplicitReturn = true
+	}
+	code := `
+		fun double(x) {
+			x * 2;
+		}
+		fun printsLast(x) {
+			x * 2;
+			print "side effect";
+		}
+		fun explicit(x) {
+			if (x > 0) return "positive";
+			"not positive";
+		}
+		print double(4);
+		print printsLast(4);
+		print explicit(1);
+		print explicit(-1);
+	`
+	assert.Equal(t, "8\nside effect\nnil\npositive\nnot positive\n", interpretWith(t, code, implicitReturn))
+	assert.Equal(t, "nil\nside effect\nnil\npositive\nnil\n", interpret(t, code))
+}
+
+func TestFluentMethods(t *testing.T) {
+	fluentMethods := func(interpreter *Interpreter) {
+		interpreter.Options.FluentMethods = true
+	}
+
+	code := `
+		class Builder {
+			setA(a) {
+				this.a = a;
+			}
+			setB(b) {
+				this.b = b;
+			}
+			get() {
+				return this.a + this.b;
+			}
+		}
+		print Builder().setA(1).setB(2).get();
+	`
+	assert.Equal(t, "3\n", interpretWith(t, code, fluentMethods))
+	assert.Equal(t, "Only instances have properties.", runtimeErrorMessage(t, code, nil))
+
+	code = `
+		class A {
+			early() {
+				return;
+			}
+		}
+		fun f() {}
+		print A().early();
+		print f();
+	`
+	assert.Equal(t, "nil\nnil\n", interpretWith(t, code, fluentMethods))
+}
+
+func TestSpecialNumbers(t *testing.T) {
+	assert.Equal(t, "Infinity\n-Infinity\nNaN\n", interpret(t, "print 1/0; print -1/0; print 0/0;"))
+	assert.Equal(t, "0\n0\n", interpret(t, "print -0; print 0 * -1;"))
+	assert.Equal(t, "[0, NaN]", stringify(NewLoxList([]any{math.Copysign(0, -1), math.NaN()})))
+}
+
+func TestThousandsSep(t *testing.T) {
+	thousandsSep := func(interpreter *Interpreter) {
+		interpreter.Options.ThousandsSep = ','
+	}
+
+	code := "print 1000000; print -1234567; print 999; print 1000; print -0;"
+	assert.Equal(t, "1e+06\n-1.234567e+06\n999\n1000\n0\n", interpret(t, code))
+	assert.Equal(t, "1,000,000\n-1,234,567\n999\n1,000\n0\n", interpretWith(t, code, thousandsSep))
+
+	code = "print 1234.5; print 1/0; print 100000000000 * 100000000000; print \"12345\";"
+	assert.Equal(t, "1234.5\nInfinity\n1e+22\n12345\n", interpretWith(t, code, thousandsSep))
+
+	code = `print [1000, ["12345", fromJSON("{\"n\": -2000}")]];`
+	assert.Equal(t, `[1,000, ["12345", {"n": -2,000}]]`+"\n", interpretWith(t, code, thousandsSep))
+}
+
+func TestPrintSepAndEnd(t *testing.T) {
+	code := `print 1, "two", nil; print 3;`
+	assert.Equal(t, "1 two nil\n3\n", interpret(t, code))
+	assert.Equal(t, "1,two,nil3", interpretWith(t, code, func(interpreter *Interpreter) {
+		interpreter.Options.PrintSep = ","
+		interpreter.Options.PrintEnd = ""
+	}))
+}
+
+func TestOptionalGet(t *testing.T) {
+	code := `
+		class Node {
+			init(next) {
+				this.next = next;
+				this.name = "node";
+			}
+		}
+		var chain = Node(Node(nil));
+		print chain?.next?.name;
+		print chain?.next?.next?.name;
+		var missing;
+		print missing?.name;
+	`
+	assert.Equal(t, "node\nnil\nnil\n", interpret(t, code))
+	assert.Equal(t, "Only instances have properties.", runtimeErrorMessage(t, `var a; print a?.b.c;`, nil))
+	assert.Equal(t, "Only instances have properties.", runtimeErrorMessage(t, `print 1?.b;`, nil))
+}
+
+func TestMinMax(t *testing.T) {
+	assert.Equal(t, "7\n2\n-1\n", interpret(t, "print max(3, 7, 2); print min(3, 7, 2); print min(-1, 5);"))
+	assert.Equal(t, "NaN\nNaN\n", interpret(t, "print max(1, 0/0, 2); print min(0/0, 1);"))
+	assert.Equal(t, "Expected at least 2 arguments but got 0.", runtimeErrorMessage(t, "max();", nil))
+	assert.Equal(t, "Expected at least 2 arguments but got 1.", runtimeErrorMessage(t, "min(1);", nil))
+	assert.Equal(t, "Arguments to 'max' must be numbers.", runtimeErrorMessage(t, `max(1, "2");`, nil))
+}
+
+func TestCallableInstances(t *testing.T) {
+	code := `
+		class Adder {
+			init(n) {
+				this.n = n;
+			}
+			call(x) {
+				return x + this.n;
+			}
+		}
+		class Incrementer < Adder {
+			init() {
+				super.init(1);
+			}
+		}
+		var add5 = Adder(5);
+		print add5(10);
+		print Incrementer()(1);
+		fun apply(f, x) {
+			return f(x);
+		}
+		print apply(add5, 1);
+	`
+	assert.Equal(t, "15\n2\n6\n", interpret(t, code))
+	assert.Equal(t, "<fn call> expected 1 arguments but got 2.", runtimeErrorMessage(t, code+"add5(1, 2);", nil))
+	assert.Equal(t, "Can only call functions and classes.", runtimeErrorMessage(t, "class A {} A()();", nil))
+}
+
+func TestFloorDivision(t *testing.T) {
+	assert.Equal(t, "3\n-4\n3\n3\nInfinity\n", interpret(t, "print 7 div 2; print -7 div 2; print 7.5 div 2.5; print 1 + 7 div 3; print 1 div 0;"))
+	assert.Equal(t, "Operands must be numbers.", runtimeErrorMessage(t, `print "7" div 2;`, nil))
+	assert.Equal(t, "Numbers don't support 'div'.", runtimeErrorMessage(t, `print 7 div 2;`, func(interpreter *Interpreter) {
+		interpreter.NewNumber = newDecimal
+	}))
+}
+
+func TestStrictEquality(t *testing.T) {
+	strict := func(interpreter *Interpreter) {
+		interpreter.Options.StrictEquality = true
+	}
+
+	assert.Equal(t, "false\nfalse\ntrue\n", interpret(t, `print 1 == "1"; print 1 == true; print 1 != true;`))
+	assert.Equal(t, "Can't compare number with string.", runtimeErrorMessage(t, `print 1 == "1";`, strict))
+	assert.Equal(t, "Can't compare number with boolean.", runtimeErrorMessage(t, `print 1 != true;`, strict))
+	assert.Equal(t, "true\nfalse\nfalse\ntrue\n", interpretWith(t, `print 1 == 1; print "a" == "b"; print 1 == nil; print nil == nil;`, strict))
+}
+
+func TestFalsiness(t *testing.T) {
+	jsFalsiness := func(interpreter *Interpreter) {
+		interpreter.Options.Falsiness = JSFalsiness
+	}
+	code := `
+		if (0) print "0 truthy"; else print "0 falsey";
+		if ("") print "'' truthy"; else print "'' falsey";
+		if (0 / 0) print "NaN truthy"; else print "NaN falsey";
+		if (1) print "1 truthy"; else print "1 falsey";
+		if ("a") print "'a' truthy"; else print "'a' falsey";
+		if (nil) print "nil truthy"; else print "nil falsey";
+		print !0;
+		print "" or "default";
+	`
+	assert.Equal(t, "0 truthy\n'' truthy\nNaN truthy\n1 truthy\n'a' truthy\nnil falsey\nfalse\n\n", interpret(t, code))
+	assert.Equal(t, "0 falsey\n'' falsey\nNaN falsey\n1 truthy\n'a' truthy\nnil falsey\ntrue\ndefault\n", interpretWith(t, code, jsFalsiness))
+
+	emptyList := func(interpreter *Interpreter) {
+		interpreter.Options.Falsiness = JSFalsiness
+		interpreter.Globals.Define("empty", NewLoxList(nil))
+		interpreter.Globals.Define("full", NewLoxList([]any{1.0}))
+	}
+	assert.Equal(t, "false\ntrue\n", interpretWith(t, `print !full; print !empty;`, emptyList))
+}
+
+func TestCurrentScope(t *testing.T) {
+	code := `
+		var g = "global";
+		fun f() {
+			var x = 1;
+			var scope = currentScope();
+			print scope.get("x");
+			print scope.get("g");
+			scope.set("x", 2);
+			print x;
+			scope.set("g", "changed");
+			scope.set("y", 3);
+			print scope.get("y");
+		}
+		f();
+		print g;
+	`
+	assert.Equal(t, "1\nglobal\n2\n3\nchanged\n", interpret(t, code))
+	assert.Equal(t, "Undefined variable 'z'.", runtimeErrorMessage(t, `currentScope().get("z");`, nil))
+	assert.Equal(t, "Variable name must be a string.", runtimeErrorMessage(t, `currentScope().set(1, 2);`, nil))
+	assert.Equal(t, "Undefined property 'keys'.", runtimeErrorMessage(t, `currentScope().keys;`, nil))
+}
+
+func TestListIndexing(t *testing.T) {
+	assert.Equal(t, "[10, 20, 30]\n10\n30\n20\n", interpret(t, "var l = [10, 20, 30]; print l; print l[0]; print l[-1]; print [10, 20, 30][-2];"))
+	assert.Equal(t, "Index out of range.", runtimeErrorMessage(t, "print [10, 20, 30][-4];", nil))
+	assert.Equal(t, "Index out of range.", runtimeErrorMessage(t, "print [10, 20, 30][3];", nil))
+	assert.Equal(t, "Index must be an integer.", runtimeErrorMessage(t, "print [10, 20, 30][1.5];", nil))
+	assert.Equal(t, "Only lists can be indexed.", runtimeErrorMessage(t, "print nil[0];", nil))
+}
+
+func TestSlices(t *testing.T) {
+	code := `
+		var l = [10, 20, 30, 40];
+		print l[1:3];
+		print l[:2];
+		print l[2:];
+		print l[:];
+		print l[-2:];
+		print l[1:100];
+		print l[3:1];
+		print "héllo"[1:4];
+		print "héllo"[-2:];
+	`
+	assert.Equal(t, "[20, 30]\n[10, 20]\n[30, 40]\n[10, 20, 30, 40]\n[30, 40]\n[20, 30, 40]\n[]\néll\nlo\n", interpret(t, code))
+	assert.Equal(t, "Slice bounds must be integers.", runtimeErrorMessage(t, `print [1, 2][0:"a"];`, nil))
+	assert.Equal(t, "Only lists and strings can be sliced.", runtimeErrorMessage(t, "print 1[0:1];", nil))
+}
+
+func TestForClosuresCaptureIteration(t *testing.T) {
+	code := `
+		var a;
+		var b;
+		var c;
+		for (var i = 0; i < 3; i = i + 1) {
+			var f = fun () { return i; };
+			if (i == 0) a = f; else if (i == 1) b = f; else c = f;
+		}
+		var closures = [a, b, c];
+		for (var j = 0; j < 3; j = j + 1) {
+			print closures[j]();
+		}
+	`
+	assert.Equal(t, "0\n1\n2\n", interpret(t, code))
+	// a closure sees changes to the loop variable made during its own iteration
+	code = `
+		var g;
+		for (var i = 0; i < 1; i = i + 1) {
+			g = fun () { return i; };
+			i = 10;
+		}
+		print g();
+	`
+	assert.Equal(t, "10\n", interpret(t, code))
+}
+
+func TestAssertEq(t *testing.T) {
+	assert.Equal(t, "ok\n", interpret(t, `assertEq(1 + 1, 2); assertEq([1, "a"], [1, "a"], "lists"); print "ok";`))
+
+	message := runtimeErrorMessage(t, `assertEq(1 + 1, 3);`, nil)
+	assert.Equal(t, "Assertion failed, expected 3 but got 2.", message)
+	message = runtimeErrorMessage(t, `assertEq([1], [2], "first");`, nil)
+	assert.Contains(t, message, "first")
+	assert.Contains(t, message, "[1]")
+	assert.Contains(t, message, "[2]")
+	assert.Equal(t, "Expected 2 or 3 arguments but got 1.", runtimeErrorMessage(t, `assertEq(1);`, nil))
+}
+
+func TestCharNatives(t *testing.T) {
+	assert.Equal(t, "A\n65\nff\n-10\né\n233\n", interpret(t, `print chr(65); print ord("A"); print hex(255); print hex(-16); print chr(233); print ord("é");`))
+	assert.Equal(t, "true\n", interpret(t, `print chr(ord("z")) == "z";`))
+	assert.Equal(t, "Argument to 'chr' must be a valid codepoint.", runtimeErrorMessage(t, `chr(-1);`, nil))
+	assert.Equal(t, "Argument to 'chr' must be a valid codepoint.", runtimeErrorMessage(t, `chr(55296);`, nil))
+	assert.Equal(t, "Argument to 'chr' must be an integer.", runtimeErrorMessage(t, `chr(65.5);`, nil))
+	assert.Equal(t, "Argument to 'ord' must be a single character string.", runtimeErrorMessage(t, `ord("AB");`, nil))
+	assert.Equal(t, "Argument to 'ord' must be a single character string.", runtimeErrorMessage(t, `ord("");`, nil))
+	assert.Equal(t, "Argument to 'hex' must be an integer.", runtimeErrorMessage(t, `hex("ff");`, nil))
+}
+
+func TestNumberToString(t *testing.T) {
+	assert.Equal(t, "ff\n11111111\n255\n-a\n1.5\n", interpret(t, `
+		print (255).toString(16);
+		print (255).toString(2);
+		print (255).toString();
+		var n = -10;
+		print n.toString(36);
+		print (1.5).toString();
+	`))
+	assert.Equal(t, "Only integers can be converted with a radix.", runtimeErrorMessage(t, `(1.5).toString(2);`, nil))
+	assert.Equal(t, "Radix must be an integer between 2 and 36.", runtimeErrorMessage(t, `(255).toString(37);`, nil))
+	assert.Equal(t, "Radix must be an integer between 2 and 36.", runtimeErrorMessage(t, `(255).toString(1);`, nil))
+	assert.Equal(t, "Only instances have properties.", runtimeErrorMessage(t, `(255).length;`, nil))
+
+	thousandsSep := func(interpreter *Interpreter) {
+		interpreter.Options.ThousandsSep = ','
+	}
+	assert.Equal(t, "1,000,000\nf4240\n", interpretWith(t, `print (1000000).toString(); print (1000000).toString(16);`, thousandsSep))
+
+	useDecimal := func(interpreter *Interpreter) {
+		interpreter.NewNumber = newDecimal
+	}
+	assert.Equal(t, "ff\n1/3\n", interpretWith(t, `print (254 + 1).toString(16); print (1 / 3).toString();`, useDecimal))
+	assert.Equal(t, "Only integers can be converted with a radix.", runtimeErrorMessage(t, `(1 / 3).toString(2);`, useDecimal))
+}
+
+func TestGetters(t *testing.T) {
+	code := `
+		class Square {
+			init(side) {
+				this.side = side;
+			}
+			area {
+				return this.side * this.side;
+			}
+			grow() {
+				this.side = this.side + 1;
+			}
+		}
+		class Cube < Square {
+			volume {
+				return super.area * this.side;
+			}
+		}
+		var s = Square(3);
+		print s.area;
+		s.grow();
+		print s.area;
+		print Cube(2).volume;
+	`
+	assert.Equal(t, "9\n16\n8\n", interpret(t, code))
+
+	assert.Equal(t, "'area' is a getter, not a method.", runtimeErrorMessage(t, code+"s.area();", nil))
+
+	strict := func(interpreter *Interpreter) {
+		interpreter.Options.StrictMethodAccess = true
+	}
+	assert.Equal(t, "'grow' is a method, not a getter.", runtimeErrorMessage(t, code+"var g = s.grow;", strict))
+	assert.Equal(t, "25\n", interpretWith(t, code+"s.grow(); print s.area;", strict)[len("9\n16\n8\n"):])
+	// without the option methods are first class values
+	assert.Equal(t, "<fn grow>\n", interpret(t, code+"print s.grow;")[len("9\n16\n8\n"):])
+}
+
+func TestCompareStrings(t *testing.T) {
+	assert.Equal(t, "true\ntrue\nfalse\ntrue\ntrue\n", interpret(t, `
+		print "apple" < "banana";
+		print "b" > "a";
+		print "b" <= "a";
+		print "abc" >= "abc";
+		print "Z" < "a";
+	`))
+	assert.Equal(t, "Operands must be two numbers or two strings.", runtimeErrorMessage(t, `print 1 < "2";`, nil))
+	assert.Equal(t, "Operands must be two numbers or two strings.", runtimeErrorMessage(t, `print "a" >= nil;`, nil))
+}
+
+func TestMathConstants(t *testing.T) {
+	assert.Equal(t, "3.141592653589793\n2.718281828459045\n6.283185307179586\n", interpret(t, `print PI; print E; print TAU;`))
+	assert.Equal(t, "Can't assign to constant 'PI'.", runtimeErrorMessage(t, `PI = 1;`, nil))
+	assert.Equal(t, "Can't assign to constant 'TAU'.", runtimeErrorMessage(t, `TAU, x = 1, 2;`, nil))
+	assert.Equal(t, "Can't assign to constant 'E'.", runtimeErrorMessage(t, `currentScope().set("E", 3);`, nil))
+	// a local can still shadow a constant
+	assert.Equal(t, "1\n3.141592653589793\n", interpret(t, `{ var PI = 1; print PI; } print PI;`))
+	// and redeclaring a global one makes it a variable
+	assert.Equal(t, "3\n4\n", interpret(t, `var PI = 3; print PI; PI = 4; print PI;`))
+	assert.Equal(t, "Can't assign to constant 'E'.", runtimeErrorMessage(t, `var PI = 3; E = 1;`, nil))
+}
+
+func TestVersion(t *testing.T) {
+	assert.Equal(t, Version+"\nstring\n", interpret(t, `print version(); print typeof version();`))
+}
+
+func TestFeatures(t *testing.T) {
+	interpreter := New()
+	features := FeaturesFunc{}.Call(&interpreter, nil).(*LoxMap)
+	for _, key := range []string{"lists", "maps", "classes", "lambdas", "bitwise", "implicitReturn", "strictEquality", "orderedMaps"} {
+		_, ok := features.Get(key)
+		assert.True(t, ok, key)
+	}
+	lists, _ := features.Get("lists")
+	assert.Equal(t, true, lists)
+	bitwise, _ := features.Get("bitwise")
+	assert.Equal(t, false, bitwise)
+	strictEquality, _ := features.Get("strictEquality")
+	assert.Equal(t, false, strictEquality)
+
+	interpreter.Options.StrictEquality = true
+	features = FeaturesFunc{}.Call(&interpreter, nil).(*LoxMap)
+	strictEquality, _ = features.Get("strictEquality")
+	assert.Equal(t, true, strictEquality)
+
+	assert.Equal(t, "map\n", interpret(t, `print typeof features();`))
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "1 + 2 = 3\n", interpret(t, `print format("{} + {} = {}", 1, 2, 3);`))
+	assert.Equal(t, `[1, "a"] nil true`+"\n", interpret(t, `print format("{} {} {}", [1, "a"], nil, true);`))
+	assert.Equal(t, "{} is 1, {x}\n", interpret(t, `print format("{{}} is {}, {{x}}", 1);`))
+	assert.Equal(t, "plain\n", interpret(t, `print format("plain");`))
+	assert.Equal(t, "Expected 2 values for the placeholders but got 1.", runtimeErrorMessage(t, `format("{} {}", 1);`, nil))
+	assert.Equal(t, "Expected 0 values for the placeholders but got 1.", runtimeErrorMessage(t, `format("none", 1);`, nil))
+	assert.Equal(t, "First argument to 'format' must be a string.", runtimeErrorMessage(t, `format(1);`, nil))
+}
+
+func TestElif(t *testing.T) {
+	code := `
+		fun sign(n) {
+			if (n > 0) {
+				return "positive";
+			} elif (n < 0) {
+				return "negative";
+			} else {
+				return "zero";
+			}
+		}
+		print sign(-2);
+		print sign(3);
+		print sign(0);
+	`
+	assert.Equal(t, "negative\npositive\nzero\n", interpret(t, code))
+}
+
+func TestUnshadow(t *testing.T) {
+	code := `
+		var clock = 1;
+		print clock;
+		unshadow("clock");
+		print clock;
+		print clock() > 0;
+	`
+	assert.Equal(t, "1\n<native fn clock>\ntrue\n", interpret(t, code))
+	assert.Equal(t, "No native function named 'nope'.", runtimeErrorMessage(t, `unshadow("nope");`, nil))
+	assert.Equal(t, "Argument to 'unshadow' must be a string.", runtimeErrorMessage(t, `unshadow(clock);`, nil))
+}
+
+func TestBind(t *testing.T) {
+	code := `
+		class Named {
+			init(name) {
+				this.name = name;
+			}
+			describe() {
+				return "I am " + this.name;
+			}
+		}
+		class Dog < Named {
+			describe() {
+				return super.describe() + ", woof";
+			}
+		}
+		class Robot {
+			init() {
+				this.name = "robot";
+			}
+		}
+		var rex = Dog("rex");
+		var robot = Robot();
+		var borrowed = bind(rex.describe, robot);
+		print borrowed();
+		print rex.describe();
+		print bind(borrowed, Dog("fido"))();
+	`
+	assert.Equal(t, "I am robot, woof\nI am rex, woof\nI am fido, woof\n", interpret(t, code))
+	assert.Equal(t, "First argument to 'bind' must be a method.", runtimeErrorMessage(t, `fun f() {} class A {} bind(f, A());`, nil))
+	assert.Equal(t, "First argument to 'bind' must be a method.", runtimeErrorMessage(t, `class A {} bind(clock, A());`, nil))
+	assert.Equal(t, "Argument to 'bind' must be an instance.", runtimeErrorMessage(t, `class A { m() {} } bind(A().m, 1);`, nil))
+}
+
+func TestStoredBoundMethods(t *testing.T) {
+	code := `
+		class Button {
+			init(label) {
+				this.label = label;
+				this.clicks = 0;
+			}
+			onClick() {
+				this.clicks = this.clicks + 1;
+				return this.label + " clicked";
+			}
+			onHover() {
+				return this.label + " hovered";
+			}
+		}
+		var ok = Button("ok");
+		var cancel = Button("cancel");
+		var handlers = [ok.onClick, cancel.onHover, cancel.onClick];
+		print handlers[0]();
+		print handlers[1]();
+		print handlers[2]();
+		print handlers[0]();
+		print ok.clicks;
+		print cancel.clicks;
+	`
+	assert.Equal(t, "ok clicked\ncancel hovered\ncancel clicked\nok clicked\n2\n1\n", interpret(t, code))
+}
+
+func TestTypeof(t *testing.T) {
+	code := `
+		class A {}
+		fun f() {}
+		print typeof 1;
+		print typeof nil;
+		print typeof "s";
+		print typeof true;
+		print typeof A;
+		print typeof A();
+		print typeof f;
+		print typeof clock;
+		print typeof [1];
+		print typeof typeof 1;
+		print typeof 1 == "number";
+	`
+	assert.Equal(t, "number\nnil\nstring\nboolean\nclass\ninstance\nfunction\nfunction\nlist\nstring\ntrue\n", interpret(t, code))
+}
+
+func TestStringifyLists(t *testing.T) {
+	assert.Equal(t, `[1, -2, [3, -4.5, []], "x", nil, true]`+"\n", interpret(t, `print [1, -2, [3, -4.5, []], "x", nil, true];`))
+	assert.Equal(t, "[0, NaN, -Infinity]\n", interpret(t, `print [-0, 0 / 0, -1 / 0];`))
+	assert.Equal(t, `["say \"hi\"\n", "tab\t"]`+"\n", interpret(t, `print ["say \"hi\"\n", "tab	"];`))
+
+	cyclic := NewLoxList([]any{1.0, nil})
+	cyclic.elements[1] = cyclic
+	assert.Equal(t, "[1, [...]]", stringify(cyclic))
+
+	shared := NewLoxList([]any{-1.0})
+	assert.Equal(t, "[[-1], [-1]]", stringify(NewLoxList([]any{shared, shared})))
+
+	m := newMap("self", nil, "list", cyclic)
+	m.Set("self", m)
+	assert.Equal(t, `{"list": [1, [...]], "self": {...}}`, stringify(m))
+}
+
+func TestLocalSubclass(t *testing.T) {
+	code := `
+		fun outer() {
+			class A {
+				name() { return "A"; }
+			}
+			class B < A {
+				n() { return "B"; }
+				name() { return "B of " + super.name(); }
+			}
+			var b = B();
+			return b.n() + ", " + b.name();
+		}
+		print outer();
+		{
+			class C {}
+			class D < C {}
+			print D();
+		}
+	`
+	assert.Equal(t, "B, B of A\nD instance\n", interpret(t, code))
+}
+
+func TestSuperInit(t *testing.T) {
+	code := `
+		class Shape {
+			init(name) {
+				this.name = name;
+				this.sides = 0;
+			}
+		}
+		class Polygon < Shape {
+			init(name, sides) {
+				var result = super.init(name);
+				this.sides = sides;
+				this.sameInstance = result == this;
+			}
+		}
+		class Square < Polygon {
+			init(side) {
+				super.init("square", 4);
+				this.side = side;
+			}
+		}
+		var s = Square(3);
+		print s.name;
+		print s.sides;
+		print s.side;
+		print s.sameInstance;
+		// an initializer called directly returns the instance it initializes
+		print s.init(5) == s;
+		print s.side;
+	`
+	assert.Equal(t, "square\n4\n3\ntrue\ntrue\n5\n", interpret(t, code))
+	assert.Equal(t, "<fn init> expected 2 arguments but got 1.", runtimeErrorMessage(t, `
+		class A { init(a, b) {} }
+		class B < A { init() { super.init(1); } }
+		B();`, nil))
+}
+
+func TestArityErrorNamesCallee(t *testing.T) {
+	assert.Equal(t, "<fn foo> expected 2 arguments but got 3.", runtimeErrorMessage(t, `fun foo(a, b) {} foo(1, 2, 3);`, nil))
+	assert.Equal(t, "<class Point> expected 2 arguments but got 1.", runtimeErrorMessage(t, `class Point { init(x, y) {} } Point(1);`, nil))
+	assert.Equal(t, "<class Empty> expected 0 arguments but got 1.", runtimeErrorMessage(t, `class Empty {} Empty(1);`, nil))
+	assert.Equal(t, "<fn> expected 0 arguments but got 1.", runtimeErrorMessage(t, `(fun () {})(1);`, nil))
+	assert.Equal(t, "Expected 0 arguments but got 1.", runtimeErrorMessage(t, `clock(1);`, nil))
+}
+
+func TestPrintCallables(t *testing.T) {
+	code := `
+		class Point {}
+		fun add(a, b) {}
+		var anonymous = fun () {};
+		print Point;
+		print Point();
+		print add;
+		print anonymous;
+		print clock;
+		print max;
+		var c = clock;
+		print c;
+	`
+	assert.Equal(t, "<class Point>\nPoint instance\n<fn add>\n<fn anonymous>\n<native fn clock>\n<native fn max>\n<native fn clock>\n", interpret(t, code))
+	// natives defined by an embedder keep their own rendering
+	assert.Equal(t, "<native fn>\n", interpretWith(t, "print explode;", func(interpreter *Interpreter) {
+		interpreter.Globals.Define("explode", panickingFunc{})
+	}))
+}
+
+func TestDestructure(t *testing.T) {
+	code := `
+		var pair = ["x", 2];
+		var (a, b) = pair;
+		print a;
+		print b;
+		fun f() {
+			var (first, second, third) = [1, [2], nil];
+			return second[0] + first;
+		}
+		print f();
+		for (var (i, n) = [0, 3]; i < n; i = i + 1) print i;
+	`
+	assert.Equal(t, "x\n2\n3\n0\n1\n2\n", interpret(t, code))
+	assert.Equal(t, "Expected 2 elements to destructure but got 3.", runtimeErrorMessage(t, `var (a, b) = [1, 2, 3];`, nil))
+	assert.Equal(t, "Expected 2 elements to destructure but got 1.", runtimeErrorMessage(t, `{ var (a, b) = [1]; }`, nil))
+	assert.Equal(t, "Can only destructure lists.", runtimeErrorMessage(t, `var (a, b) = "ab";`, nil))
+}
+
+func TestMultiAssign(t *testing.T) {
+	code := `
+		var a = 1;
+		var b = 2;
+		a, b = b, a;
+		print a;
+		print b;
+		fun fib(n) {
+			var x = 0;
+			var y = 1;
+			for (var i = 0; i < n; i = i + 1) {
+				x, y = y, x + y;
+			}
+			return x;
+		}
+		print fib(10);
+		class Pair {}
+		var p = Pair();
+		p.first, p.second, a = "f", "s", 3;
+		print p.first + p.second;
+		print a;
+	`
+	assert.Equal(t, "2\n1\n55\nfs\n3\n", interpret(t, code))
+	assert.Equal(t, "Only instances have fields.", runtimeErrorMessage(t, `var a; var b = nil; a, b.c = 1, 2;`, nil))
+}
+
+func TestFieldsSetBySuperInit(t *testing.T) {
+	code := `
+		class Base {
+			init() {
+				this.base = 10;
+			}
+		}
+		class Derived < Base {
+			init() {
+				super.init();
+				print this.base;
+				this.derived = this.base * 2;
+			}
+			total() {
+				return this.base + this.derived;
+			}
+		}
+		var d = Derived();
+		print d.base;
+		print d.total();
+	`
+	assert.Equal(t, "10\n10\n30\n", interpret(t, code))
+}
+
+func TestInitEarlyReturn(t *testing.T) {
+	code := `
+		class Account {
+			init(balance) {
+				this.balance = 0;
+				if (balance < 0) return;
+				this.balance = balance;
+			}
+		}
+		var bad = Account(-5);
+		print bad;
+		print bad.balance;
+		print Account(10).balance;
+		// calling init again directly also yields the instance
+		print bad.init(-1) == bad;
+	`
+	assert.Equal(t, "Account instance\n0\n10\ntrue\n", interpret(t, code))
+}
+
+func TestBlockExpr(t *testing.T) {
+	code := `
+		var x = { var t = 2; t * t };
+		print x;
+		var t = "outer";
+		print { var t = "inner"; t } + " " + t;
+		print { print "side effect"; };
+		fun abs(n) {
+			return { if (n < 0) return -n; n };
+		}
+		print abs(-3);
+		print abs(5);
+		{ print "statement block"; }
+	`
+	assert.Equal(t, "4\ninner outer\nside effect\nnil\n3\n5\nstatement block\n", interpret(t, code))
+}
+
+func TestInheritedMethodLookup(t *testing.T) {
+	code := `
+		class A {
+			name() { return "A"; }
+			greet() { return "hi from " + this.name(); }
+		}
+		class B < A {}
+		class C < B {
+			name() { return "C"; }
+		}
+		var b = B();
+		var c = C();
+		for (var i = 0; i < 2; i = i + 1) {
+			print b.greet();
+			print c.greet();
+		}
+		print A().greet();
+	`
+	assert.Equal(t, "hi from A\nhi from C\nhi from A\nhi from C\nhi from A\n", interpret(t, code))
+	assert.Equal(t, "Undefined property 'missing'.", runtimeErrorMessage(t, `class A {} class B < A {} var b = B(); print b.missing; print b.missing;`, nil))
+}
+
+func BenchmarkInheritedMethodCalls(b *testing.B) {
+	code := `
+		class Base {
+			inc(n) { return n + 1; }
+		}
+		class Middle < Base {}
+		class Leaf < Middle {}
+		var leaf = Leaf();
+		var n = 0;
+		for (var i = 0; i < 10000; i = i + 1) {
+			n = leaf.inc(n);
+		}
+	`
+	for i := 0; i < b.N; i++ {
+		interpret(b, code)
+	}
+}
+
+func TestReturnFromNestedBlocks(t *testing.T) {
+	code := `
+		fun find(list, target) {
+			for (var i = 0; i < 10; i = i + 1) {
+				{
+					if (list[i] == target) {
+						while (true) {
+							return i;
+						}
+					}
+				}
+			}
+			return -1;
+		}
+		print find([3, 5, 7, 9, 11, 13, 15, 17, 19, 21], 9);
+		print find([3, 5, 7, 9, 11, 13, 15, 17, 19, 21], 4);
+
+		fun early(n) {
+			if (n > 0) { return "positive"; } else if (n < 0) return "negative";
+			print "zero";
+		}
+		print early(1);
+		print early(-1);
+		print early(0);
+
+		fun loop() {
+			var n = 0;
+			while (true) {
+				n = n + 1;
+				{ if (n == 3) break; }
+			}
+			return n;
+		}
+		print loop();
+		fun fromBlockExpr(n) {
+			var x = { if (n) return "returned"; "fell through" };
+			return x;
+		}
+		print fromBlockExpr(true);
+		print fromBlockExpr(false);
+	`
+	assert.Equal(t, "3\n-1\npositive\nnegative\nzero\nnil\n3\nreturned\nfell through\n", interpret(t, code))
+}
+
+func BenchmarkRecursiveReturns(b *testing.B) {
+	code := `
+		fun fib(n) {
+			if (n < 2) return n;
+			return fib(n - 1) + fib(n - 2);
+		}
+		fib(20);
+	`
+	for i := 0; i < b.N; i++ {
+		interpret(b, code)
+	}
+}
+
+func TestReusedCallEnvironments(t *testing.T) {
+	code := `
+		fun square(n) { var result = n * n; return result; }
+		fun sum(n) {
+			if (n == 0) return 0;
+			return square(n) + sum(n - 1);
+		}
+		print sum(4);
+		print sum(3);
+
+		fun makeCounter() {
+			var count = 0;
+			fun increment() { count = count + 1; return count; }
+			return increment;
+		}
+		var a = makeCounter();
+		var b = makeCounter();
+		a();
+		a();
+		square(7);
+		print a();
+		print b();
+
+		fun exposeScope(n) { var local = n; return currentScope(); }
+		var scope = exposeScope(1);
+		square(2);
+		exposeScope(3);
+		print scope.get("local");
+	`
+	assert.Equal(t, "30\n14\n3\n1\n1\n", interpret(t, code))
+}
+
+func BenchmarkLeafCalls(b *testing.B) {
+	code := `
+		fun add(a, b) { return a + b; }
+		var n = 0;
+		for (var i = 0; i < 10000; i = i + 1) {
+			n = add(n, i);
+		}
+	`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		interpret(b, code)
+	}
+}
+
+func TestLocalSlots(t *testing.T) {
+	code := `
+		var a = "global a";
+		{
+			var a = "outer a";
+			var b = "outer b";
+			{
+				print a;
+				var a = "inner a";
+				var c = "inner c";
+				print a + ", " + b + ", " + c;
+				b = "assigned b";
+				var scope = currentScope();
+				scope.set("dynamic", "d");
+				var d = "after dynamic";
+				print d + ", " + scope.get("dynamic");
+			}
+			print a + ", " + b;
+		}
+		print a;
+		fun f(x, y) {
+			var z = x + y;
+			{ var x = z * 2; z = x; }
+			return [x, y, z];
+		}
+		print f(1, 2);
+		class A {
+			init(v) { this.v = v; }
+			get() { return this.v; }
+		}
+		class B < A {
+			get() { var extra = 1; return super.get() + extra; }
+		}
+		print B(41).get();
+	`
+	assert.Equal(t, "outer a\ninner a, outer b, inner c\nafter dynamic, d\nouter a, assigned b\nglobal a\n[1, 2, 6]\n42\n", interpret(t, code))
+}
+
+func BenchmarkLocalVariables(b *testing.B) {
+	code := `
+		fun run() {
+			var a = 1;
+			var b = 2;
+			var c = 0;
+			for (var i = 0; i < 10000; i = i + 1) {
+				var t = a + b;
+				c = c + t - i;
+			}
+			return c;
+		}
+		run();
+	`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		interpret(b, code)
+	}
+}
+
+func TestCapturingClosureRoundTrip(t *testing.T) {
+	code := `
+		var count = "global";
+		fun counter() {
+			var count = 0;
+			return fun () {
+				count = count + 1;
+				return count;
+			};
+		}
+		var next = counter();
+		next();
+		print next();
+		print count;
+	`
+	var locals int
+	output := interpretWith(t, code, func(interpreter *Interpreter) {
+		locals = len(interpreter.Locals)
+	})
+	assert.Equal(t, "2\nglobal\n", output)
+	// the assignment and both reads of the captured count
+	assert.Equal(t, 3, locals)
 }
@@ -0,0 +1,111 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxSafeInteger is the largest integer a float64 holds exactly
+const maxSafeInteger = 1<<53 - 1
+
+func checkInteger(value any, function string) int64 {
+	if n, ok := value.(float64); ok && n == math.Trunc(n) && math.Abs(n) <= maxSafeInteger {
+		return int64(n)
+	}
+	panic(nativeError{message: "Argument to '" + function + "' must be an integer."})
+}
+
+type ChrFunc struct{}
+
+func (ChrFunc) Arity() int {
+	return 1
+}
+
+func (ChrFunc) Call(interpreter *Interpreter, arguments []any) any {
+	n := checkInteger(arguments[0], "chr")
+	if n < 0 || n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+		panic(nativeError{message: "Argument to 'chr' must be a valid codepoint."})
+	}
+	return string(rune(n))
+}
+
+func (ChrFunc) String() string {
+	return "<native fn>"
+}
+
+type OrdFunc struct{}
+
+func (OrdFunc) Arity() int {
+	return 1
+}
+
+func (OrdFunc) Call(interpreter *Interpreter, arguments []any) any {
+	s, ok := arguments[0].(string)
+	if !ok || utf8.RuneCountInString(s) != 1 {
+		panic(nativeError{message: "Argument to 'ord' must be a single character string."})
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return float64(r)
+}
+
+func (OrdFunc) String() string {
+	return "<native fn>"
+}
+
+type HexFunc struct{}
+
+func (HexFunc) Arity() int {
+	return 1
+}
+
+func (HexFunc) Call(interpreter *Interpreter, arguments []any) any {
+	return strconv.FormatInt(checkInteger(arguments[0], "hex"), 16)
+}
+
+func (HexFunc) String() string {
+	return "<native fn>"
+}
+
+// numberToStringFunc is the toString method of a number, formatting it like print does or
+// in a radix from 2 to 36, e.g. (255).toString(16) is "ff"
+type numberToStringFunc struct {
+	number any
+}
+
+func (numberToStringFunc) Arity() int {
+	return Variadic
+}
+
+func (f numberToStringFunc) Call(interpreter *Interpreter, arguments []any) any {
+	switch len(arguments) {
+	case 0:
+		return interpreter.stringify(f.number)
+	case 1:
+	default:
+		panic(nativeError{message: fmt.Sprintf("Expected 0 or 1 arguments but got %d.", len(arguments))})
+	}
+	radix, ok := arguments[0].(float64)
+	if !ok || radix != math.Trunc(radix) || radix < 2 || radix > 36 {
+		panic(nativeError{message: "Radix must be an integer between 2 and 36."})
+	}
+	n, ok := f.integer()
+	if !ok {
+		panic(nativeError{message: "Only integers can be converted with a radix."})
+	}
+	return strconv.FormatInt(n, int(radix))
+}
+
+func (f numberToStringFunc) integer() (int64, bool) {
+	if n, ok := f.number.(float64); ok {
+		return int64(n), n == math.Trunc(n) && math.Abs(n) <= maxSafeInteger
+	}
+	// a custom Number is an integer if it prints as one
+	n, err := strconv.ParseInt(stringify(f.number), 10, 64)
+	return n, err == nil
+}
+
+func (numberToStringFunc) String() string {
+	return "<native fn>"
+}
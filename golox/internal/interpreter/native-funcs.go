@@ -1,6 +1,10 @@
 package interpreter
 
-import "time"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 type ClockFunc struct{}
 
@@ -9,9 +13,423 @@ func (ClockFunc) Arity() int {
 }
 
 func (ClockFunc) Call(interpreter *Interpreter, arguments []any) any {
-	return float64(time.Now().UnixMilli()) / 1000
+	return float64(interpreter.Now().UnixMilli()) / 1000
 }
 
 func (ClockFunc) String() string {
 	return "<native fn>"
 }
+
+type DebugScopeFunc struct{}
+
+func (DebugScopeFunc) Arity() int {
+	return 0
+}
+
+// Call prints every variable visible from the caller's environment, innermost
+// scope first, skipping names shadowed by an inner scope.
+func (DebugScopeFunc) Call(interpreter *Interpreter, arguments []any) any {
+	seen := make(map[string]bool)
+	for env := interpreter.environment; env != nil; env = env.enclosing {
+		var names []string
+		for _, name := range env.variables() {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			value, _ := env.get(name)
+			interpreter.Print(fmt.Sprintf("%s = %s\n", name, stringify(value)))
+		}
+	}
+	return nil
+}
+
+func (DebugScopeFunc) String() string {
+	return "<native fn>"
+}
+
+// nativeError is raised by native functions, which don't know their call site;
+// the interpreter reports it as a RuntimeError at the call's closing paren.
+type nativeError struct {
+	message string
+}
+
+func checkInstance(value any, function string) *LoxInstance {
+	if instance, ok := value.(*LoxInstance); ok {
+		return instance
+	}
+	panic(nativeError{message: "Argument to '" + function + "' must be an instance."})
+}
+
+func checkFieldName(value any) string {
+	if name, ok := value.(string); ok {
+		return name
+	}
+	panic(nativeError{message: "Field name must be a string."})
+}
+
+type FieldsFunc struct{}
+
+func (FieldsFunc) Arity() int {
+	return 1
+}
+
+func (FieldsFunc) Call(interpreter *Interpreter, arguments []any) any {
+	instance := checkInstance(arguments[0], "fields")
+
+	names := make([]string, 0, len(instance.fields))
+	for name := range instance.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	elements := make([]any, len(names))
+	for i, name := range names {
+		elements[i] = name
+	}
+	return NewLoxList(elements)
+}
+
+func (FieldsFunc) String() string {
+	return "<native fn>"
+}
+
+type GetFieldFunc struct{}
+
+func (GetFieldFunc) Arity() int {
+	return 2
+}
+
+func (GetFieldFunc) Call(interpreter *Interpreter, arguments []any) any {
+	instance := checkInstance(arguments[0], "getField")
+	name := checkFieldName(arguments[1])
+
+	value, ok := instance.fields[name]
+	if !ok {
+		panic(nativeError{message: "Undefined field '" + name + "'."})
+	}
+	return value
+}
+
+func (GetFieldFunc) String() string {
+	return "<native fn>"
+}
+
+type SetFieldFunc struct{}
+
+func (SetFieldFunc) Arity() int {
+	return 3
+}
+
+func (SetFieldFunc) Call(interpreter *Interpreter, arguments []any) any {
+	instance := checkInstance(arguments[0], "setField")
+	name := checkFieldName(arguments[1])
+
+	instance.fields[name] = arguments[2]
+	return arguments[2]
+}
+
+func (SetFieldFunc) String() string {
+	return "<native fn>"
+}
+
+type MinFunc struct{}
+
+func (MinFunc) Arity() int {
+	return Variadic
+}
+
+func (MinFunc) Call(interpreter *Interpreter, arguments []any) any {
+	return extremum(interpreter, arguments, "min", -1)
+}
+
+func (MinFunc) String() string {
+	return "<native fn>"
+}
+
+type MaxFunc struct{}
+
+func (MaxFunc) Arity() int {
+	return Variadic
+}
+
+func (MaxFunc) Call(interpreter *Interpreter, arguments []any) any {
+	return extremum(interpreter, arguments, "max", 1)
+}
+
+func (MaxFunc) String() string {
+	return "<native fn>"
+}
+
+// extremum returns the smallest argument for a negative sign or the largest for a positive
+// one. Like math.Min and math.Max, it's NaN if any argument is NaN.
+func extremum(interpreter *Interpreter, arguments []any, function string, sign int) any {
+	if len(arguments) < 2 {
+		panic(nativeError{message: fmt.Sprintf("Expected at least 2 arguments but got %d.", len(arguments))})
+	}
+
+	var result any
+	var best Number
+	for _, argument := range arguments {
+		n, ok := interpreter.toNumber(argument)
+		if !ok {
+			panic(nativeError{message: "Arguments to '" + function + "' must be numbers."})
+		}
+
+		if best == nil {
+			result, best = argument, n
+			continue
+		}
+		c := n.Compare(best)
+		// once NaN is picked, comparing to it is always unordered
+		if (c == Unordered && n.Compare(n) == Unordered) || (c != Unordered && c*sign > 0) {
+			result, best = argument, n
+		}
+	}
+	return result
+}
+
+// AssertEqFunc is assertEq(actual, expected) with an optional message, for self-checking scripts.
+type AssertEqFunc struct{}
+
+func (AssertEqFunc) Arity() int {
+	return Variadic
+}
+
+func (AssertEqFunc) Call(interpreter *Interpreter, arguments []any) any {
+	if len(arguments) != 2 && len(arguments) != 3 {
+		panic(nativeError{message: fmt.Sprintf("Expected 2 or 3 arguments but got %d.", len(arguments))})
+	}
+
+	actual, expected := arguments[0], arguments[1]
+	if isEqual(actual, expected) {
+		return nil
+	}
+	message := "Assertion failed"
+	if len(arguments) == 3 {
+		message += ": " + stringify(arguments[2])
+	}
+	panic(nativeError{message: fmt.Sprintf("%s, expected %s but got %s.", message, stringify(expected), stringify(actual))})
+}
+
+func (AssertEqFunc) String() string {
+	return "<native fn>"
+}
+
+// BindFunc is bind(method, instance), returning the method bound to another instance.
+type BindFunc struct{}
+
+func (BindFunc) Arity() int {
+	return 2
+}
+
+func (BindFunc) Call(interpreter *Interpreter, arguments []any) any {
+	method, ok := arguments[0].(*LoxFunction)
+	if !ok || !method.isMethod {
+		panic(nativeError{message: "First argument to 'bind' must be a method."})
+	}
+	instance := checkInstance(arguments[1], "bind")
+
+	// rebind the method as declared, the closure of a bound method is the environment defining `this`
+	unbound := *method
+	unbound.closure = method.closure.enclosing
+	return unbound.Bind(instance)
+}
+
+func (BindFunc) String() string {
+	return "<native fn>"
+}
+
+// UnshadowFunc is unshadow(name), restoring a native function redefined by a global.
+type UnshadowFunc struct{}
+
+func (UnshadowFunc) Arity() int {
+	return 1
+}
+
+func (UnshadowFunc) Call(interpreter *Interpreter, arguments []any) any {
+	name, ok := arguments[0].(string)
+	if !ok {
+		panic(nativeError{message: "Argument to 'unshadow' must be a string."})
+	}
+	native, ok := interpreter.natives[name]
+	if !ok {
+		panic(nativeError{message: "No native function named '" + name + "'."})
+	}
+	defineNative(interpreter.Globals, name, native)
+	return nil
+}
+
+func (UnshadowFunc) String() string {
+	return "<native fn>"
+}
+
+// FormatFunc is format(template, values...), replacing each `{}` in the template with the
+// next value as print would show it. `{{` and `}}` are literal braces.
+type FormatFunc struct{}
+
+func (FormatFunc) Arity() int {
+	return Variadic
+}
+
+func (FormatFunc) Call(interpreter *Interpreter, arguments []any) any {
+	if len(arguments) == 0 {
+		panic(nativeError{message: "Expected at least 1 argument but got 0."})
+	}
+	template, ok := arguments[0].(string)
+	if !ok {
+		panic(nativeError{message: "First argument to 'format' must be a string."})
+	}
+	values := arguments[1:]
+
+	var result strings.Builder
+	placeholders := 0
+	for i := 0; i < len(template); i++ {
+		switch {
+		case strings.HasPrefix(template[i:], "{{"), strings.HasPrefix(template[i:], "}}"):
+			result.WriteByte(template[i])
+			i++
+		case strings.HasPrefix(template[i:], "{}"):
+			if placeholders < len(values) {
+				result.WriteString(interpreter.stringify(values[placeholders]))
+			}
+			placeholders++
+			i++
+		default:
+			result.WriteByte(template[i])
+		}
+	}
+
+	if placeholders != len(values) {
+		panic(nativeError{message: fmt.Sprintf("Expected %d values for the placeholders but got %d.", placeholders, len(values))})
+	}
+	return result.String()
+}
+
+func (FormatFunc) String() string {
+	return "<native fn>"
+}
+
+// FeaturesFunc reports which optional language features the interpreter supports or has
+// enabled, so a script can adapt to them
+type FeaturesFunc struct{}
+
+func (FeaturesFunc) Arity() int {
+	return 0
+}
+
+func (FeaturesFunc) Call(interpreter *Interpreter, arguments []any) any {
+	options := interpreter.Options
+	features := interpreter.newMap()
+	for _, feature := range []struct {
+		name    string
+		enabled bool
+	}{
+		// always part of the language
+		{"lists", true},
+		{"maps", true},
+		{"classes", true},
+		{"lambdas", true},
+		{"bitwise", false},
+		// enabled by options
+		{"implicitReturn", options.ImplicitReturn},
+		{"strictEquality", options.StrictEquality},
+		{"jsFalsiness", options.Falsiness == JSFalsiness},
+		{"fluentMethods", options.FluentMethods},
+		{"thousandsSep", options.ThousandsSep != 0},
+		{"orderedMaps", options.OrderedMaps},
+		{"strictMethodAccess", options.StrictMethodAccess},
+	} {
+		features.Set(feature.name, feature.enabled)
+	}
+	return features
+}
+
+func (FeaturesFunc) String() string {
+	return "<native fn>"
+}
+
+type VersionFunc struct{}
+
+func (VersionFunc) Arity() int {
+	return 0
+}
+
+func (VersionFunc) Call(interpreter *Interpreter, arguments []any) any {
+	return Version
+}
+
+func (VersionFunc) String() string {
+	return "<native fn>"
+}
+
+// RangeFunc returns a lazy range of integers, range(start, end) counting up by 1 and
+// range(start, end, step) by step, which can be negative to count down
+type RangeFunc struct{}
+
+func (RangeFunc) Arity() int {
+	return Variadic
+}
+
+func (RangeFunc) Call(interpreter *Interpreter, arguments []any) any {
+	if len(arguments) != 2 && len(arguments) != 3 {
+		panic(nativeError{message: fmt.Sprintf("Expected 2 or 3 arguments but got %d.", len(arguments))})
+	}
+	r := &LoxRange{start: checkInteger(arguments[0], "range"), end: checkInteger(arguments[1], "range"), step: 1}
+	if len(arguments) == 3 {
+		r.step = checkInteger(arguments[2], "range")
+	}
+	if r.step == 0 {
+		panic(nativeError{message: "Range step can't be zero."})
+	}
+	return r
+}
+
+func (RangeFunc) String() string {
+	return "<native fn>"
+}
+
+func checkMap(value any, function string) *LoxMap {
+	if m, ok := value.(*LoxMap); ok {
+		return m
+	}
+	panic(nativeError{message: "Argument to '" + function + "' must be a map."})
+}
+
+// MapSetFunc sets a key of a map, adding it after the existing keys of an ordered map
+type MapSetFunc struct{}
+
+func (MapSetFunc) Arity() int {
+	return 3
+}
+
+func (MapSetFunc) Call(interpreter *Interpreter, arguments []any) any {
+	checkMap(arguments[0], "mapSet").Set(arguments[1], arguments[2])
+	return arguments[2]
+}
+
+func (MapSetFunc) String() string {
+	return "<native fn>"
+}
+
+// MapRemoveFunc removes a key from a map, returning its value or nil if it wasn't there
+type MapRemoveFunc struct{}
+
+func (MapRemoveFunc) Arity() int {
+	return 2
+}
+
+func (MapRemoveFunc) Call(interpreter *Interpreter, arguments []any) any {
+	m := checkMap(arguments[0], "mapRemove")
+	value, _ := m.Get(arguments[1])
+	m.Delete(arguments[1])
+	return value
+}
+
+func (MapRemoveFunc) String() string {
+	return "<native fn>"
+}
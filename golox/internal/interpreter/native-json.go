@@ -0,0 +1,117 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+type ToJSONFunc struct{}
+
+func (ToJSONFunc) Arity() int {
+	return 1
+}
+
+func (ToJSONFunc) Call(interpreter *Interpreter, arguments []any) any {
+	value := toJSONValue(interpreter, arguments[0], make(map[any]bool))
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		panic(nativeError{message: "Could not convert to JSON: " + err.Error()})
+	}
+	return string(bytes)
+}
+
+func (ToJSONFunc) String() string {
+	return "<native fn>"
+}
+
+// toJSONValue converts a Lox value into the plain Go value encoding/json expects.
+// active holds the collections currently being converted, to reject cycles.
+func toJSONValue(interpreter *Interpreter, value any, active map[any]bool) any {
+	switch v := value.(type) {
+	case nil, bool, float64, string:
+		return v
+	case *LoxList:
+		if active[v] {
+			panic(nativeError{message: "Can't convert a cyclic structure to JSON."})
+		}
+		active[v] = true
+		defer delete(active, v)
+
+		elements := make([]any, len(v.elements))
+		for i, element := range v.elements {
+			elements[i] = toJSONValue(interpreter, element, active)
+		}
+		return elements
+	case *LoxMap:
+		if active[v] {
+			panic(nativeError{message: "Can't convert a cyclic structure to JSON."})
+		}
+		active[v] = true
+		defer delete(active, v)
+
+		entries := make(map[string]any, len(v.entries))
+		for key, value := range v.entries {
+			name, ok := key.(string)
+			if !ok {
+				panic(nativeError{message: "Map keys must be strings to convert to JSON."})
+			}
+			entries[name] = toJSONValue(interpreter, value, active)
+		}
+		return entries
+	case *LoxInstance:
+		method := v.class.FindMethod("toJSON")
+		if method == nil || method.Arity() != 0 {
+			panic(nativeError{message: "Can't convert " + v.String() + " to JSON without a toJSON() method."})
+		}
+		return toJSONValue(interpreter, method.Bind(v).Call(interpreter, nil), active)
+	}
+
+	panic(nativeError{message: "Can't convert " + stringify(value) + " to JSON."})
+}
+
+type FromJSONFunc struct{}
+
+func (FromJSONFunc) Arity() int {
+	return 1
+}
+
+func (FromJSONFunc) Call(interpreter *Interpreter, arguments []any) any {
+	str, ok := arguments[0].(string)
+	if !ok {
+		panic(nativeError{message: "Argument to 'fromJSON' must be a string."})
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(str), &value); err != nil {
+		panic(nativeError{message: "Invalid JSON: " + err.Error()})
+	}
+	return fromJSONValue(interpreter, value)
+}
+
+func (FromJSONFunc) String() string {
+	return "<native fn>"
+}
+
+func fromJSONValue(interpreter *Interpreter, value any) any {
+	switch v := value.(type) {
+	case []any:
+		elements := make([]any, len(v))
+		for i, element := range v {
+			elements[i] = fromJSONValue(interpreter, element)
+		}
+		return NewLoxList(elements)
+	case map[string]any:
+		m := interpreter.newMap()
+		// the decoded object doesn't keep the document's order, insert sorted to be deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			m.Set(key, fromJSONValue(interpreter, v[key]))
+		}
+		return m
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package interpreter
+
+import (
+	"github.com/michael-go/lox/golox/internal/globals"
+	"github.com/michael-go/lox/golox/internal/token"
+)
+
+// propertyHolder is a value whose properties can be read with a get expression
+type propertyHolder interface {
+	Get(name token.Token) any
+}
+
+type CurrentScopeFunc struct{}
+
+func (CurrentScopeFunc) Arity() int {
+	return 0
+}
+
+func (CurrentScopeFunc) Call(interpreter *Interpreter, arguments []any) any {
+	for env := interpreter.environment; env != nil; env = env.enclosing {
+		env.captured = true
+	}
+	return &LoxScope{environment: interpreter.environment}
+}
+
+func (CurrentScopeFunc) String() string {
+	return "<native fn>"
+}
+
+// LoxScope exposes an environment to Lox code, with get(name) reading a variable
+// through the lexical chain and set(name, value) assigning it where it's defined,
+// or defining it in the scope itself.
+type LoxScope struct {
+	environment *Environment
+}
+
+func (s *LoxScope) Get(name token.Token) any {
+	switch name.Lexeme {
+	case "get":
+		return scopeGetFunc{s}
+	case "set":
+		return scopeSetFunc{s}
+	}
+	panic(globals.RuntimeError{Token: name, Message: "Undefined property '" + name.Lexeme + "'."})
+}
+
+func (s *LoxScope) String() string {
+	return "<scope>"
+}
+
+func checkVariableName(value any) string {
+	if name, ok := value.(string); ok {
+		return name
+	}
+	panic(nativeError{message: "Variable name must be a string."})
+}
+
+type scopeGetFunc struct {
+	scope *LoxScope
+}
+
+func (scopeGetFunc) Arity() int {
+	return 1
+}
+
+func (f scopeGetFunc) Call(interpreter *Interpreter, arguments []any) any {
+	name := checkVariableName(arguments[0])
+	env := f.scope.environment.lookup(name)
+	if env == nil {
+		panic(nativeError{message: "Undefined variable '" + name + "'."})
+	}
+	value, _ := env.get(name)
+	return value
+}
+
+func (scopeGetFunc) String() string {
+	return "<native fn>"
+}
+
+type scopeSetFunc struct {
+	scope *LoxScope
+}
+
+func (scopeSetFunc) Arity() int {
+	return 2
+}
+
+func (f scopeSetFunc) Call(interpreter *Interpreter, arguments []any) any {
+	name := checkVariableName(arguments[0])
+	env := f.scope.environment.lookup(name)
+	if env == nil {
+		env = f.scope.environment
+	}
+	if env.constants[name] {
+		panic(nativeError{message: "Can't assign to constant '" + name + "'."})
+	}
+	env.set(name, arguments[1])
+	return arguments[1]
+}
+
+func (scopeSetFunc) String() string {
+	return "<native fn>"
+}
@@ -0,0 +1,82 @@
+package interpreter
+
+import "math"
+
+// Number is the arithmetic behind Lox numbers. Number literals and natives produce
+// float64 values, which the interpreter converts with Interpreter.NewNumber before
+// operating on them, so an embedder can swap in e.g. an exact decimal type.
+type Number interface {
+	Add(other Number) Number
+	Sub(other Number) Number
+	Mul(other Number) Number
+	Div(other Number) Number
+	Neg() Number
+	// Compare returns a negative number, zero or a positive number when the receiver
+	// is less than, equal to or greater than other, or Unordered when they have no order.
+	Compare(other Number) int
+}
+
+const Unordered = math.MinInt
+
+// Floorer is implemented by Numbers supporting the floor division `div` operator.
+type Floorer interface {
+	Floor() Number
+}
+
+// Float is the default float64 backed Number.
+type Float float64
+
+func (f Float) Add(other Number) Number {
+	return f + other.(Float)
+}
+
+func (f Float) Sub(other Number) Number {
+	return f - other.(Float)
+}
+
+func (f Float) Mul(other Number) Number {
+	return f * other.(Float)
+}
+
+func (f Float) Div(other Number) Number {
+	return f / other.(Float)
+}
+
+func (f Float) Neg() Number {
+	return -f
+}
+
+func (f Float) Floor() Number {
+	return Float(math.Floor(float64(f)))
+}
+
+func (f Float) Compare(other Number) int {
+	o := other.(Float)
+	switch {
+	case f < o:
+		return -1
+	case f > o:
+		return 1
+	case f == o:
+		return 0
+	}
+	return Unordered
+}
+
+func (i *Interpreter) toNumber(value any) (Number, bool) {
+	switch v := value.(type) {
+	case float64:
+		return i.NewNumber(v), true
+	case Number:
+		return v, true
+	}
+	return nil, false
+}
+
+// fromNumber unwraps the default Float so that numbers stay plain float64 values at runtime.
+func fromNumber(n Number) any {
+	if f, ok := n.(Float); ok {
+		return float64(f)
+	}
+	return n
+}
@@ -0,0 +1,335 @@
+package optimizer
+
+import (
+	"math"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/token"
+)
+
+// Fold replaces unary and binary expressions over literal operands with the literal
+// they evaluate to, e.g. `2 * 3` becomes `6`. Nodes are rewritten in place, so it
+// must run before the resolver binds expressions. Folding uses float64 arithmetic,
+// so it shouldn't be used with a custom interpreter Number implementation.
+func Fold(statements []ast.Stmt) []ast.Stmt {
+	f := folder{}
+	for _, statement := range statements {
+		f.stmt(statement)
+	}
+	return statements
+}
+
+type folder struct{}
+
+func (f *folder) stmt(stmt ast.Stmt) {
+	// a statement with a syntax error is nil
+	if stmt != nil {
+		stmt.Accept(f)
+	}
+}
+
+func (f *folder) stmts(statements []ast.Stmt) {
+	for _, statement := range statements {
+		f.stmt(statement)
+	}
+}
+
+func (f *folder) expr(expr ast.Expr) ast.Expr {
+	if expr == nil {
+		return nil
+	}
+	return expr.Accept(f).(ast.Expr)
+}
+
+func foldUnary(operator token.Type, right any) (any, bool) {
+	switch operator {
+	case token.MINUS:
+		if r, ok := right.(float64); ok {
+			return -r, true
+		}
+	case token.BANG:
+		// only nil and booleans, as other values' truthiness may depend on interpreter options
+		if right == nil {
+			return true, true
+		}
+		if r, ok := right.(bool); ok {
+			return !r, true
+		}
+	}
+	return nil, false
+}
+
+func foldBinary(operator token.Type, left any, right any) (any, bool) {
+	switch l := left.(type) {
+	case float64:
+		r, ok := right.(float64)
+		if !ok {
+			return nil, false
+		}
+		switch operator {
+		case token.PLUS:
+			return l + r, true
+		case token.MINUS:
+			return l - r, true
+		case token.STAR:
+			return l * r, true
+		case token.SLASH:
+			if r == 0 {
+				return nil, false
+			}
+			return l / r, true
+		case token.DIV:
+			if r == 0 {
+				return nil, false
+			}
+			return math.Floor(l / r), true
+		case token.GREATER:
+			return l > r, true
+		case token.GREATER_EQUAL:
+			return l >= r, true
+		case token.LESS:
+			return l < r, true
+		case token.LESS_EQUAL:
+			return l <= r, true
+		case token.EQUAL_EQUAL:
+			return l == r, true
+		case token.BANG_EQUAL:
+			return l != r, true
+		}
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return nil, false
+		}
+		switch operator {
+		case token.PLUS:
+			return l + r, true
+		case token.EQUAL_EQUAL:
+			return l == r, true
+		case token.BANG_EQUAL:
+			return l != r, true
+		}
+	case bool:
+		r, ok := right.(bool)
+		if !ok {
+			return nil, false
+		}
+		switch operator {
+		case token.EQUAL_EQUAL:
+			return l == r, true
+		case token.BANG_EQUAL:
+			return l != r, true
+		}
+	}
+	return nil, false
+}
+
+func (f *folder) VisitBlockStmt(stmt *ast.Block) any {
+	f.stmts(stmt.Statements)
+	return nil
+}
+
+func (f *folder) VisitBreakStmt(stmt *ast.Break) any {
+	return nil
+}
+
+func (f *folder) VisitClassStmt(stmt *ast.Class) any {
+	for _, method := range stmt.Methods {
+		f.stmts(method.Body)
+	}
+	return nil
+}
+
+func (f *folder) VisitExpressionStmt(stmt *ast.Expression) any {
+	stmt.Expression = f.expr(stmt.Expression)
+	return nil
+}
+
+func (f *folder) VisitFunctionStmt(stmt *ast.Function) any {
+	f.stmts(stmt.Body)
+	return nil
+}
+
+func (f *folder) VisitIfStmt(stmt *ast.If) any {
+	stmt.Condition = f.expr(stmt.Condition)
+	f.stmt(stmt.ThenBranch)
+	f.stmt(stmt.ElseBranch)
+	return nil
+}
+
+func (f *folder) VisitPrintStmt(stmt *ast.Print) any {
+	for i, expr := range stmt.Expressions {
+		stmt.Expressions[i] = f.expr(expr)
+	}
+	return nil
+}
+
+func (f *folder) VisitEprintStmt(stmt *ast.Eprint) any {
+	stmt.Expression = f.expr(stmt.Expression)
+	return nil
+}
+
+func (f *folder) VisitReturnStmt(stmt *ast.Return) any {
+	stmt.Value = f.expr(stmt.Value)
+	return nil
+}
+
+func (f *folder) VisitMultiAssignStmt(stmt *ast.MultiAssign) any {
+	for i, target := range stmt.Targets {
+		stmt.Targets[i] = f.expr(target)
+	}
+	for i, value := range stmt.Values {
+		stmt.Values[i] = f.expr(value)
+	}
+	return nil
+}
+
+func (f *folder) VisitDestructureStmt(stmt *ast.Destructure) any {
+	stmt.Initializer = f.expr(stmt.Initializer)
+	return nil
+}
+
+func (f *folder) VisitVarStmt(stmt *ast.Var) any {
+	stmt.Initializer = f.expr(stmt.Initializer)
+	return nil
+}
+
+func (f *folder) VisitWhileStmt(stmt *ast.While) any {
+	stmt.Condition = f.expr(stmt.Condition)
+	f.stmt(stmt.Body)
+	if stmt.Increment != nil {
+		stmt.Increment = f.expr(stmt.Increment)
+	}
+	if stmt.ElseBranch != nil {
+		f.stmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (f *folder) VisitForEachStmt(stmt *ast.ForEach) any {
+	stmt.Iterable = f.expr(stmt.Iterable)
+	f.stmt(stmt.Body)
+	return nil
+}
+
+func (f *folder) VisitAssignExpr(expr *ast.Assign) any {
+	expr.Value = f.expr(expr.Value)
+	return expr
+}
+
+func (f *folder) VisitBinaryExpr(expr *ast.Binary) any {
+	expr.Left = f.expr(expr.Left)
+	expr.Right = f.expr(expr.Right)
+
+	left, okLeft := expr.Left.(*ast.Literal)
+	right, okRight := expr.Right.(*ast.Literal)
+	if okLeft && okRight {
+		if value, ok := foldBinary(expr.Operator.Type, left.Value, right.Value); ok {
+			return &ast.Literal{Value: value}
+		}
+	}
+	return expr
+}
+
+func (f *folder) VisitCallExpr(expr *ast.Call) any {
+	expr.Callee = f.expr(expr.Callee)
+	for i, arg := range expr.Arguments {
+		expr.Arguments[i] = f.expr(arg)
+	}
+	return expr
+}
+
+func (f *folder) VisitGetExpr(expr *ast.Get) any {
+	expr.Object = f.expr(expr.Object)
+	return expr
+}
+
+func (f *folder) VisitListExpr(expr *ast.List) any {
+	for i, element := range expr.Elements {
+		expr.Elements[i] = f.expr(element)
+	}
+	return expr
+}
+
+func (f *folder) VisitSliceExpr(expr *ast.Slice) any {
+	expr.Object = f.expr(expr.Object)
+	if expr.Start != nil {
+		expr.Start = f.expr(expr.Start)
+	}
+	if expr.End != nil {
+		expr.End = f.expr(expr.End)
+	}
+	return expr
+}
+
+func (f *folder) VisitRangeExpr(expr *ast.Range) any {
+	expr.Start = f.expr(expr.Start)
+	expr.End = f.expr(expr.End)
+	return expr
+}
+
+func (f *folder) VisitSubscriptExpr(expr *ast.Subscript) any {
+	expr.Object = f.expr(expr.Object)
+	expr.Index = f.expr(expr.Index)
+	return expr
+}
+
+func (f *folder) VisitGroupingExpr(expr *ast.Grouping) any {
+	expr.Expression = f.expr(expr.Expression)
+	if literal, ok := expr.Expression.(*ast.Literal); ok {
+		return literal
+	}
+	return expr
+}
+
+func (f *folder) VisitBlockExprExpr(expr *ast.BlockExpr) any {
+	f.stmts(expr.Statements)
+	if expr.Value != nil {
+		expr.Value = f.expr(expr.Value)
+	}
+	return expr
+}
+
+func (f *folder) VisitLambdaExpr(expr *ast.Lambda) any {
+	f.stmts(expr.Function.Body)
+	return expr
+}
+
+func (f *folder) VisitLiteralExpr(expr *ast.Literal) any {
+	return expr
+}
+
+func (f *folder) VisitLogicalExpr(expr *ast.Logical) any {
+	expr.Left = f.expr(expr.Left)
+	expr.Right = f.expr(expr.Right)
+	return expr
+}
+
+func (f *folder) VisitSetExpr(expr *ast.Set) any {
+	expr.Object = f.expr(expr.Object)
+	expr.Value = f.expr(expr.Value)
+	return expr
+}
+
+func (f *folder) VisitSuperExpr(expr *ast.Super) any {
+	return expr
+}
+
+func (f *folder) VisitThisExpr(expr *ast.This) any {
+	return expr
+}
+
+func (f *folder) VisitUnaryExpr(expr *ast.Unary) any {
+	expr.Right = f.expr(expr.Right)
+
+	if right, ok := expr.Right.(*ast.Literal); ok {
+		if value, ok := foldUnary(expr.Operator.Type, right.Value); ok {
+			return &ast.Literal{Value: value}
+		}
+	}
+	return expr
+}
+
+func (f *folder) VisitVariableExpr(expr *ast.Variable) any {
+	return expr
+}
@@ -0,0 +1,116 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/michael-go/go-jsn/jsn"
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/globals"
+	"github.com/michael-go/lox/golox/internal/interpreter"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/resolver"
+	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, code string) []ast.Stmt {
+	scan := scanner.New(code)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+
+	parser := parser.New(tokens)
+	return parser.Parse()
+}
+
+func astJson(t *testing.T, statements []ast.Stmt) string {
+	json, err := jsn.NewJson(statements)
+	if err != nil {
+		t.Fatalf("failed to AST convert to json: %v", err)
+	}
+	return json.Pretty()
+}
+
+func run(statements []ast.Stmt) string {
+	interp := interpreter.New()
+	var result string
+	interp.Print = func(str string) {
+		result = result + str
+	}
+	resolver := resolver.New(&interp)
+	resolver.Resolve(statements)
+	interp.Interpret(statements)
+	return result
+}
+
+func TestFold(t *testing.T) {
+	assert.Equal(t, `[
+  {
+    "Expressions": [
+      {
+        "Value": 7
+      }
+    ]
+  },
+  {
+    "Expressions": [
+      {
+        "Value": false
+      }
+    ]
+  },
+  {
+    "Expressions": [
+      {
+        "Value": "foobar"
+      }
+    ]
+  },
+  {
+    "Expressions": [
+      {
+        "Value": true
+      }
+    ]
+  }
+]`, astJson(t, Fold(parse(t, `print 2 * 3 + 1; print !true; print "foo" + "bar"; print -(1 - 2) >= 1;`))))
+}
+
+func TestFoldLeavesNonConstants(t *testing.T) {
+	code := `var x = 1; print x * (2 + 3); print 1 / 0; print !0; print 1 == "1";`
+	assert.Equal(t, astJson(t, parse(t, `var x = 1; print x * 5; print 1 / 0; print !0; print 1 == "1";`)), astJson(t, Fold(parse(t, code))))
+}
+
+func TestFoldKeepsRuntimeResults(t *testing.T) {
+	code := `
+		var x = 2;
+		print x * (2 + 3);
+		print (1 + 2) * 3 - 4 / 5;
+		print -7 div 2;
+		print "a" + "b" == "ab";
+		fun f() {
+			return -(-3) < 2 or !nil;
+		}
+		print f();
+	`
+	assert.Equal(t, run(parse(t, code)), run(Fold(parse(t, code))))
+	assert.Equal(t, "10\n8.2\n-4\ntrue\ntrue\n", run(Fold(parse(t, code))))
+}
+
+func TestFoldDivisionByZero(t *testing.T) {
+	folded := Fold(parse(t, `print 1 / (2 - 2); print 4 / 2; print 7 div 0; var x = 1; print x / 0;`))
+	assert.Equal(t, astJson(t, parse(t, `print 1 / 0; print 2; print 7 div 0; var x = 1; print x / 0;`)), astJson(t, folded))
+
+	restore := globals.Capture()
+	defer restore()
+
+	interp := interpreter.New()
+	resolver := resolver.New(&interp)
+	resolver.Options.WarnDivisionByZero = true
+	resolver.Resolve(folded)
+	assert.Equal(t, []string{
+		"[line 1] Warning at '/': Division by zero in constant expression.",
+		"[line 1] Warning at 'div': Division by zero in constant expression.",
+	}, restore())
+}
@@ -1,14 +1,32 @@
 package parser
 
 import (
+	"fmt"
+
 	"github.com/michael-go/lox/golox/internal/ast"
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
+type Options struct {
+	// AutoSemicolons lets a statement's `;` be omitted before a token starting a new line or at
+	// the end of input. Like in JavaScript, a line that can continue the statement still does.
+	AutoSemicolons bool
+	// WarnNoEffect reports expression statements that have no side effects, like `x == 1;`
+	WarnNoEffect bool
+	// RejectAmbiguousComparisons reports an error for an equality whose operand is an
+	// unparenthesized comparison, like `a < b == c`
+	RejectAmbiguousComparisons bool
+}
+
 type Parser struct {
 	tokens  []token.Token
 	current int
+	// thenBranch is set while parsing the then-branch of an if outside of braces, where an
+	// else after a while loop belongs to the if
+	thenBranch bool
+
+	Options Options
 }
 
 type ParserError struct {
@@ -20,14 +38,46 @@ func New(tokens []token.Token) Parser {
 }
 
 func (p *Parser) Parse() []ast.Stmt {
-	var statements []ast.Stmt
+	// an empty program is valid, and parses to no statements rather than nil
+	statements := []ast.Stmt{}
 	for !p.isAtEnd() {
-		statements = append(statements, p.decleration())
+		statement, _ := p.ParseStatement()
+		statements = append(statements, statement)
 	}
 
 	return statements
 }
 
+// ParseStatement parses the next top-level statement and reports whether more are available.
+// On a syntax error the statement is nil and the parser is synchronized to the next one.
+func (p *Parser) ParseStatement() (ast.Stmt, bool) {
+	if p.isAtEnd() {
+		return nil, false
+	}
+
+	statement := p.decleration()
+	return statement, !p.isAtEnd()
+}
+
+// ParseExpression parses the tokens as a single expression, which must be followed by
+// nothing but the end of input. On a syntax error the expression is nil.
+func (p *Parser) ParseExpression() (expr ast.Expr) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(ParserError); !ok {
+				panic(r)
+			}
+			expr = nil
+		}
+	}()
+
+	expr = p.expression()
+	if !p.isAtEnd() {
+		p.panicError(p.peek(), "Expect end of expression.")
+	}
+	return expr
+}
+
 func (p *Parser) decleration() ast.Stmt {
 	recorver := func() {
 		if r := recover(); r != nil {
@@ -44,7 +94,8 @@ func (p *Parser) decleration() ast.Stmt {
 	if p.match(token.CLASS) {
 		return p.classDecleration()
 	}
-	if p.match(token.FUN) {
+	if p.check(token.FUN) && p.checkNext(token.IDENTIFIER) {
+		p.advance()
 		return p.function("function")
 	}
 	if p.match(token.VAR) {
@@ -67,7 +118,7 @@ func (p *Parser) classDecleration() ast.Stmt {
 
 	var methods []*ast.Function
 	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
-		methods = append(methods, p.function("method"))
+		methods = append(methods, p.method())
 	}
 
 	p.consume(token.RIGHT_BRACE, "Expect '}' after class body.")
@@ -78,6 +129,30 @@ func (p *Parser) classDecleration() ast.Stmt {
 func (p *Parser) function(kind string) *ast.Function {
 	name := p.consume(token.IDENTIFIER, "Expect "+kind+" name.")
 	p.consume(token.LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	parameters, body := p.functionRest(kind)
+
+	return &ast.Function{Name: name, Params: parameters, Body: body}
+}
+
+// method parses a method, or a getter if the name isn't followed by a parameter list
+func (p *Parser) method() *ast.Function {
+	if p.check(token.IDENTIFIER) && p.checkNext(token.LEFT_BRACE) {
+		name := p.advance()
+		p.advance()
+		return &ast.Function{Name: name, Body: p.block(), Getter: true}
+	}
+	return p.function("method")
+}
+
+func (p *Parser) lambda() ast.Expr {
+	keyword := p.previous()
+	p.consume(token.LEFT_PAREN, "Expect '(' after 'fun'.")
+	parameters, body := p.functionRest("function")
+
+	return &ast.Lambda{Keyword: keyword, Function: &ast.Function{Params: parameters, Body: body}}
+}
+
+func (p *Parser) functionRest(kind string) ([]token.Token, []ast.Stmt) {
 	parameters := make([]token.Token, 0)
 	if !p.check(token.RIGHT_PAREN) {
 		for {
@@ -97,10 +172,13 @@ func (p *Parser) function(kind string) *ast.Function {
 	p.consume(token.LEFT_BRACE, "Expect '{' before "+kind+" body.")
 	body := p.block()
 
-	return &ast.Function{Name: name, Params: parameters, Body: body}
+	return parameters, body
 }
 
 func (p *Parser) varDecleration() ast.Stmt {
+	if p.match(token.LEFT_PAREN) {
+		return p.destructure()
+	}
 	name := p.consume(token.IDENTIFIER, "Expect variable name.")
 
 	var initializer ast.Expr
@@ -108,11 +186,32 @@ func (p *Parser) varDecleration() ast.Stmt {
 		initializer = p.expression()
 	}
 
-	p.consume(token.SEMICOLON, "Expect ';' after variable declaration.")
+	p.consumeSemicolon("Expect ';' after variable declaration.")
 	return &ast.Var{Name: name, Initializer: initializer}
 }
 
+// destructure parses `var (a, b) = list;`, binding each name to an element of the list
+func (p *Parser) destructure() ast.Stmt {
+	paren := p.previous()
+	var names []token.Token
+	for {
+		names = append(names, p.consume(token.IDENTIFIER, "Expect variable name."))
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	p.consume(token.RIGHT_PAREN, "Expect ')' after variable names.")
+	p.consume(token.EQUAL, "Expect '=' after destructured variables.")
+	initializer := p.expression()
+
+	p.consumeSemicolon("Expect ';' after variable declaration.")
+	return &ast.Destructure{Paren: paren, Names: names, Initializer: initializer}
+}
+
 func (p *Parser) statement() ast.Stmt {
+	if p.match(token.BREAK) {
+		return p.breakStatement()
+	}
 	if p.match(token.FOR) {
 		return p.forStatement()
 	}
@@ -122,6 +221,9 @@ func (p *Parser) statement() ast.Stmt {
 	if p.match(token.PRINT) {
 		return p.printStatement()
 	}
+	if p.match(token.EPRINT) {
+		return p.eprintStatement()
+	}
 	if p.match(token.RETURN) {
 		return p.returnStatement()
 	}
@@ -138,22 +240,34 @@ func (p *Parser) statement() ast.Stmt {
 func (p *Parser) returnStatement() ast.Stmt {
 	keyword := p.previous()
 	var value ast.Expr
-	if !p.check(token.SEMICOLON) {
+	if !p.check(token.SEMICOLON) && !p.canInsertSemicolon() {
 		value = p.expression()
 	}
 
-	p.consume(token.SEMICOLON, "Expect ';' after return value.")
+	p.consumeSemicolon("Expect ';' after return value.")
 	return &ast.Return{Keyword: keyword, Value: value}
 }
 
+func (p *Parser) breakStatement() ast.Stmt {
+	keyword := p.previous()
+	p.consumeSemicolon("Expect ';' after 'break'.")
+	return &ast.Break{Keyword: keyword}
+}
+
 func (p *Parser) forStatement() ast.Stmt {
+	keyword := p.previous()
 	p.consume(token.LEFT_PAREN, "Expect '(' after 'for'.")
 
+	isVar := p.match(token.VAR)
+	if p.check(token.IDENTIFIER) && p.checkNext(token.IN) {
+		return p.forEachStatement(keyword)
+	}
+
 	var initializer ast.Stmt
-	if p.match(token.SEMICOLON) {
-		initializer = nil
-	} else if p.match(token.VAR) {
+	if isVar {
 		initializer = p.varDecleration()
+	} else if p.match(token.SEMICOLON) {
+		initializer = nil
 	} else {
 		initializer = p.expressionStatement()
 	}
@@ -172,13 +286,16 @@ func (p *Parser) forStatement() ast.Stmt {
 
 	body := p.statement()
 
-	if increment != nil {
-		body = &ast.Block{Statements: []ast.Stmt{body, &ast.Expression{Expression: increment}}}
-	}
 	if condition == nil {
 		condition = &ast.Literal{Value: true}
 	}
-	body = &ast.While{Condition: condition, Body: body}
+	// a loop variable gets a fresh binding per iteration, so closures capture its value
+	var perIteration bool
+	switch initializer.(type) {
+	case *ast.Var, *ast.Destructure:
+		perIteration = true
+	}
+	body = &ast.While{Keyword: keyword, Condition: condition, Body: body, Increment: increment, PerIteration: perIteration}
 
 	if initializer != nil {
 		body = &ast.Block{Statements: []ast.Stmt{initializer, body}}
@@ -187,13 +304,33 @@ func (p *Parser) forStatement() ast.Stmt {
 	return body
 }
 
+// forEachStatement parses the rest of `for (name in iterable) body`, the loop variable
+// being declared, with or without var, for each iteration
+func (p *Parser) forEachStatement(keyword token.Token) ast.Stmt {
+	name := p.consume(token.IDENTIFIER, "Expect loop variable name.")
+	p.consume(token.IN, "Expect 'in' after loop variable.")
+	iterable := p.expression()
+	p.consume(token.RIGHT_PAREN, "Expect ')' after for clauses.")
+	body := p.statement()
+
+	return &ast.ForEach{Keyword: keyword, Name: name, Iterable: iterable, Body: body}
+}
+
 func (p *Parser) whileStatement() ast.Stmt {
+	keyword := p.previous()
 	p.consume(token.LEFT_PAREN, "Expect '(' after 'while'.")
 	condition := p.expression()
 	p.consume(token.RIGHT_PAREN, "Expect ')' after condition.")
 	body := p.statement()
 
-	return &ast.While{Condition: condition, Body: body}
+	// in `if (a) while (b) c; else d;` the else has always been the if's, so a while only
+	// takes an else where it can't be mistaken for one
+	var elseBranch ast.Stmt
+	if !p.thenBranch && p.match(token.ELSE) {
+		elseBranch = p.statement()
+	}
+
+	return &ast.While{Keyword: keyword, Condition: condition, Body: body, ElseBranch: elseBranch}
 }
 
 func (p *Parser) ifStatement() ast.Stmt {
@@ -201,10 +338,17 @@ func (p *Parser) ifStatement() ast.Stmt {
 	condition := p.expression()
 	p.consume(token.RIGHT_PAREN, "Expect ')' after if condition.")
 
+	enclosing := p.thenBranch
+	p.thenBranch = true
 	thenBranch := p.statement()
+	p.thenBranch = enclosing
+
 	var elseBranch ast.Stmt
 	if p.match(token.ELSE) {
 		elseBranch = p.statement()
+	} else if p.match(token.ELIF) {
+		// `elif (b) ...` is `else if (b) ...`
+		elseBranch = p.ifStatement()
 	}
 
 	return &ast.If{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}
@@ -213,6 +357,10 @@ func (p *Parser) ifStatement() ast.Stmt {
 func (p *Parser) block() []ast.Stmt {
 	var statements []ast.Stmt
 
+	enclosing := p.thenBranch
+	p.thenBranch = false
+	defer func() { p.thenBranch = enclosing }()
+
 	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
 		statements = append(statements, p.decleration())
 	}
@@ -222,17 +370,75 @@ func (p *Parser) block() []ast.Stmt {
 }
 
 func (p *Parser) printStatement() ast.Stmt {
+	values := []ast.Expr{p.expression()}
+	for p.match(token.COMMA) {
+		values = append(values, p.expression())
+	}
+	p.consumeSemicolon("Expect ';' after value.")
+	return &ast.Print{Expressions: values}
+}
+
+func (p *Parser) eprintStatement() ast.Stmt {
 	value := p.expression()
-	p.consume(token.SEMICOLON, "Expect ';' after value.")
-	return &ast.Print{Expression: value}
+	p.consumeSemicolon("Expect ';' after value.")
+	return &ast.Eprint{Expression: value}
 }
 
 func (p *Parser) expressionStatement() ast.Stmt {
-	expr := p.expression()
-	p.consume(token.SEMICOLON, "Expect ';' after expression.")
+	start := p.peek()
+	return p.finishExpressionStatement(start, p.expression())
+}
+
+// finishExpressionStatement parses the rest of a statement starting with the expression
+func (p *Parser) finishExpressionStatement(start token.Token, expr ast.Expr) ast.Stmt {
+	if p.check(token.COMMA) {
+		return p.multiAssign(expr)
+	}
+	p.consumeSemicolon("Expect ';' after expression.")
+	if p.Options.WarnNoEffect && !hasSideEffects(expr) {
+		globals.ReportWarning(start.File, start.Line, where(start), "Expression statement has no effect.")
+	}
 	return &ast.Expression{Expression: expr}
 }
 
+// multiAssign parses the rest of `a, b = b, a;` after its first target
+func (p *Parser) multiAssign(first ast.Expr) ast.Stmt {
+	targets := []ast.Expr{first}
+	for p.match(token.COMMA) {
+		targets = append(targets, p.or())
+	}
+	for _, target := range targets {
+		if !isAssignable(target) {
+			p.panicError(p.peek(), "Invalid assignment target.")
+		}
+	}
+	equals := p.consume(token.EQUAL, "Expect '=' after assignment targets.")
+
+	var values []ast.Expr
+	for {
+		values = append(values, p.expression())
+		if !p.match(token.COMMA) {
+			break
+		}
+	}
+	if len(values) != len(targets) {
+		p.reportError(equals, fmt.Sprintf("Expected %d values but got %d.", len(targets), len(values)))
+	}
+
+	p.consumeSemicolon("Expect ';' after assignment.")
+	return &ast.MultiAssign{Targets: targets, Equals: equals, Values: values}
+}
+
+func isAssignable(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Variable:
+		return true
+	case *ast.Get:
+		return !expr.Optional
+	}
+	return false
+}
+
 func (p *Parser) expression() ast.Expr {
 	return p.assignment()
 }
@@ -246,7 +452,7 @@ func (p *Parser) assignment() ast.Expr {
 
 		if name, ok := expr.(*ast.Variable); ok {
 			return &ast.Assign{Name: name.Name, Value: value}
-		} else if get, ok := expr.(*ast.Get); ok {
+		} else if get, ok := expr.(*ast.Get); ok && !get.Optional {
 			return &ast.Set{Object: get.Object, Name: get.Name, Value: value}
 		}
 
@@ -286,24 +492,51 @@ func (p *Parser) equality() ast.Expr {
 	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
 		operator := p.previous()
 		right := p.comparison()
+		if p.Options.RejectAmbiguousComparisons && (isComparison(expr) || isComparison(right)) {
+			// the expression is still well formed, so keep parsing
+			p.reportError(operator, "Ambiguous comparison chain; add parentheses.")
+		}
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
 
 	return expr
 }
 
+func isComparison(expr ast.Expr) bool {
+	if binary, ok := expr.(*ast.Binary); ok {
+		switch binary.Operator.Type {
+		case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Parser) comparison() ast.Expr {
-	expr := p.term()
+	expr := p.rangeExpr()
 
 	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
 		operator := p.previous()
-		right := p.term()
+		right := p.rangeExpr()
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
 
 	return expr
 }
 
+// rangeExpr parses `start..end` or the inclusive `start..=end`, which don't chain
+func (p *Parser) rangeExpr() ast.Expr {
+	expr := p.term()
+
+	if p.match(token.DOT_DOT, token.DOT_DOT_EQUAL) {
+		operator := p.previous()
+		end := p.term()
+		expr = &ast.Range{Start: expr, Operator: operator, End: end, Inclusive: operator.Type == token.DOT_DOT_EQUAL}
+	}
+
+	return expr
+}
+
 func (p *Parser) term() ast.Expr {
 	expr := p.factor()
 
@@ -319,7 +552,7 @@ func (p *Parser) term() ast.Expr {
 func (p *Parser) factor() ast.Expr {
 	expr := p.unary()
 
-	for p.match(token.SLASH, token.STAR) {
+	for p.match(token.SLASH, token.STAR, token.DIV) {
 		operator := p.previous()
 		right := p.unary()
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
@@ -329,7 +562,7 @@ func (p *Parser) factor() ast.Expr {
 }
 
 func (p *Parser) unary() ast.Expr {
-	if p.match(token.BANG, token.MINUS) {
+	if p.match(token.BANG, token.MINUS, token.TYPEOF) {
 		operator := p.previous()
 		right := p.unary()
 		return &ast.Unary{Operator: operator, Right: right}
@@ -347,6 +580,11 @@ func (p *Parser) call() ast.Expr {
 		} else if p.match(token.DOT) {
 			name := p.consume(token.IDENTIFIER, "Expect property name after '.'.")
 			expr = &ast.Get{Object: expr, Name: name}
+		} else if p.match(token.QUESTION_DOT) {
+			name := p.consume(token.IDENTIFIER, "Expect property name after '?.'.")
+			expr = &ast.Get{Object: expr, Name: name, Optional: true}
+		} else if p.match(token.LEFT_BRACKET) {
+			expr = p.subscript(expr)
 		} else {
 			break
 		}
@@ -355,13 +593,35 @@ func (p *Parser) call() ast.Expr {
 	return expr
 }
 
+// subscript parses an index `[i]` or a slice `[start:end]` with optional bounds
+func (p *Parser) subscript(object ast.Expr) ast.Expr {
+	var start ast.Expr
+	if !p.check(token.COLON) {
+		start = p.expression()
+		if !p.check(token.COLON) {
+			bracket := p.consume(token.RIGHT_BRACKET, "Expect ']' after index.")
+			return &ast.Subscript{Object: object, Bracket: bracket, Index: start}
+		}
+	}
+	p.advance()
+
+	var end ast.Expr
+	if !p.check(token.RIGHT_BRACKET) {
+		end = p.expression()
+	}
+	bracket := p.consume(token.RIGHT_BRACKET, "Expect ']' after slice.")
+	return &ast.Slice{Object: object, Bracket: bracket, Start: start, End: end}
+}
+
 func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
 	var arguments []ast.Expr = make([]ast.Expr, 0)
+	var spread []bool = make([]bool, 0)
 	if !p.check(token.RIGHT_PAREN) {
 		for {
 			if len(arguments) >= 255 {
 				p.panicError(p.peek(), "Can't have more than 255 arguments.")
 			}
+			spread = append(spread, p.match(token.DOT_DOT_DOT))
 			arguments = append(arguments, p.expression())
 			if !p.match(token.COMMA) {
 				break
@@ -371,7 +631,7 @@ func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
 
 	paren := p.consume(token.RIGHT_PAREN, "Expect ')' after arguments.")
 
-	return &ast.Call{Callee: callee, Paren: paren, Arguments: arguments}
+	return &ast.Call{Callee: callee, Paren: paren, Arguments: arguments, Spread: spread}
 }
 
 func (p *Parser) primary() ast.Expr {
@@ -385,8 +645,13 @@ func (p *Parser) primary() ast.Expr {
 		return &ast.Literal{Value: nil}
 	}
 
-	if p.match(token.NUMBER, token.STRING) {
-		return &ast.Literal{Value: p.previous().Literal}
+	if p.match(token.NUMBER) {
+		value, _ := p.previous().NumberValue()
+		return &ast.Literal{Value: value}
+	}
+	if p.match(token.STRING) {
+		value, _ := p.previous().StringValue()
+		return &ast.Literal{Value: value}
 	}
 
 	if p.match(token.SUPER) {
@@ -400,11 +665,23 @@ func (p *Parser) primary() ast.Expr {
 		return &ast.This{Keyword: p.previous()}
 	}
 
+	if p.match(token.FUN) {
+		return p.lambda()
+	}
+
 	if p.match(token.IDENTIFIER) {
 		r := &ast.Variable{Name: p.previous()}
 		return r
 	}
 
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+
+	if p.match(token.LEFT_BRACE) {
+		return p.blockExpr()
+	}
+
 	if p.match(token.LEFT_PAREN) {
 		expr := p.expression()
 		p.consume(token.RIGHT_PAREN, "Expect ')' after expression.")
@@ -415,6 +692,62 @@ func (p *Parser) primary() ast.Expr {
 	return nil
 }
 
+func (p *Parser) list() ast.Expr {
+	elements := make([]ast.Expr, 0)
+	if !p.check(token.RIGHT_BRACKET) {
+		for {
+			elements = append(elements, p.expression())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	bracket := p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements.")
+	return &ast.List{Bracket: bracket, Elements: elements}
+}
+
+// blockExpr parses `{ stmt; ... expr }` in expression position, where a final
+// expression without a `;` is the value of the block
+func (p *Parser) blockExpr() ast.Expr {
+	var statements []ast.Stmt
+	var value ast.Expr
+
+	enclosing := p.thenBranch
+	p.thenBranch = false
+	defer func() { p.thenBranch = enclosing }()
+
+	for !p.check(token.RIGHT_BRACE) && !p.isAtEnd() {
+		if p.startsStatement() {
+			statements = append(statements, p.decleration())
+			continue
+		}
+
+		start := p.peek()
+		expr := p.expression()
+		if p.check(token.RIGHT_BRACE) {
+			value = expr
+			break
+		}
+		statements = append(statements, p.finishExpressionStatement(start, expr))
+	}
+
+	brace := p.consume(token.RIGHT_BRACE, "Expect '}' after block.")
+	return &ast.BlockExpr{Brace: brace, Statements: statements, Value: value}
+}
+
+// startsStatement reports whether the next token begins a statement that isn't an expression
+func (p *Parser) startsStatement() bool {
+	switch p.peek().Type {
+	case token.CLASS, token.VAR, token.BREAK, token.FOR, token.IF, token.PRINT, token.EPRINT,
+		token.RETURN, token.WHILE, token.LEFT_BRACE:
+		return true
+	case token.FUN:
+		return p.checkNext(token.IDENTIFIER)
+	}
+	return false
+}
+
 func (p *Parser) consume(tokenType token.Type, message string) token.Token {
 	if p.check(tokenType) {
 		return p.advance()
@@ -424,17 +757,61 @@ func (p *Parser) consume(tokenType token.Type, message string) token.Token {
 	return token.Token{}
 }
 
+// hasSideEffects reports whether evaluating the expression can change the program's state
+func hasSideEffects(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Call, *ast.Assign, *ast.Set:
+		return true
+	case *ast.Binary:
+		return hasSideEffects(expr.Left) || hasSideEffects(expr.Right)
+	case *ast.Logical:
+		return hasSideEffects(expr.Left) || hasSideEffects(expr.Right)
+	case *ast.Unary:
+		return hasSideEffects(expr.Right)
+	case *ast.Grouping:
+		return hasSideEffects(expr.Expression)
+	case *ast.Get:
+		return hasSideEffects(expr.Object)
+	case *ast.Subscript:
+		return hasSideEffects(expr.Object) || hasSideEffects(expr.Index)
+	case *ast.Slice:
+		return hasSideEffects(expr.Object) || (expr.Start != nil && hasSideEffects(expr.Start)) || (expr.End != nil && hasSideEffects(expr.End))
+	case *ast.List:
+		for _, element := range expr.Elements {
+			if hasSideEffects(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// consumeSemicolon consumes the `;` ending a statement, unless it can be omitted
+func (p *Parser) consumeSemicolon(message string) {
+	if p.check(token.SEMICOLON) || !p.canInsertSemicolon() {
+		p.consume(token.SEMICOLON, message)
+	}
+}
+
+func (p *Parser) canInsertSemicolon() bool {
+	return p.Options.AutoSemicolons && (p.isAtEnd() || p.peek().NewlineBefore)
+}
+
 func (p *Parser) panicError(t token.Token, message string) {
 	p.reportError(t, message)
 	panic(ParserError{message: message})
 }
 
 func (p *Parser) reportError(t token.Token, message string) {
+	globals.ReportError(t.File, t.Line, where(t), message)
+}
+
+// where describes the location of the token in a report
+func where(t token.Token) string {
 	if t.Type == token.EOF {
-		globals.ReportError(t.Line, " at end", message)
-	} else {
-		globals.ReportError(t.Line, " at '"+t.Lexeme+"'", message)
+		return " at end"
 	}
+	return " at '" + t.Lexeme + "'"
 }
 
 func (p *Parser) match(types ...token.Type) bool {
@@ -454,6 +831,13 @@ func (p *Parser) check(tokenType token.Type) bool {
 	return p.peek().Type == tokenType
 }
 
+func (p *Parser) checkNext(tokenType token.Type) bool {
+	if p.isAtEnd() || p.current+1 >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.current+1].Type == tokenType
+}
+
 func (p *Parser) advance() token.Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -489,6 +873,7 @@ func (p *Parser) synchronize() {
 		case token.IF:
 		case token.WHILE:
 		case token.PRINT:
+		case token.EPRINT:
 		case token.RETURN:
 			return
 		}
@@ -0,0 +1,391 @@
+package printer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/token"
+)
+
+const indentUnit = "  "
+
+// Printer renders an AST back into formatted Lox source.
+type Printer struct {
+	builder strings.Builder
+	indent  int
+}
+
+func Print(statements []ast.Stmt) string {
+	p := Printer{}
+	for _, statement := range statements {
+		p.writeIndent()
+		p.stmt(statement)
+		p.builder.WriteString("\n")
+	}
+	return p.builder.String()
+}
+
+func (p *Printer) stmt(stmt ast.Stmt) {
+	stmt.Accept(p)
+}
+
+func (p *Printer) expr(expr ast.Expr) string {
+	return expr.Accept(p).(string)
+}
+
+func (p *Printer) write(strs ...string) {
+	for _, str := range strs {
+		p.builder.WriteString(str)
+	}
+}
+
+func (p *Printer) writeIndent() {
+	p.builder.WriteString(strings.Repeat(indentUnit, p.indent))
+}
+
+func (p *Printer) block(statements []ast.Stmt) {
+	p.write("{\n")
+	p.indent++
+	for _, statement := range statements {
+		p.writeIndent()
+		p.stmt(statement)
+		p.write("\n")
+	}
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+}
+
+// body renders a statement nested under a control-flow header: blocks stay on
+// the header line, anything else goes indented on its own line.
+func (p *Printer) body(stmt ast.Stmt) {
+	if block, ok := stmt.(*ast.Block); ok {
+		p.write(" ")
+		p.block(block.Statements)
+		return
+	}
+
+	p.write("\n")
+	p.indent++
+	p.writeIndent()
+	p.stmt(stmt)
+	p.indent--
+}
+
+func (p *Printer) function(stmt *ast.Function) {
+	if stmt.Getter {
+		p.write(stmt.Name.Lexeme, " ")
+	} else {
+		p.write(stmt.Name.Lexeme, "(", params(stmt.Params), ") ")
+	}
+	p.block(stmt.Body)
+}
+
+func params(tokens []token.Token) string {
+	names := make([]string, len(tokens))
+	for i, param := range tokens {
+		names[i] = param.Lexeme
+	}
+	return strings.Join(names, ", ")
+}
+
+func (p *Printer) VisitBlockStmt(stmt *ast.Block) any {
+	p.block(stmt.Statements)
+	return nil
+}
+
+func (p *Printer) VisitBreakStmt(stmt *ast.Break) any {
+	p.write("break;")
+	return nil
+}
+
+func (p *Printer) VisitClassStmt(stmt *ast.Class) any {
+	p.write("class ", stmt.Name.Lexeme)
+	if stmt.Superclass != nil {
+		p.write(" < ", stmt.Superclass.Name.Lexeme)
+	}
+	p.write(" {\n")
+	p.indent++
+	for _, method := range stmt.Methods {
+		p.writeIndent()
+		p.function(method)
+		p.write("\n")
+	}
+	p.indent--
+	p.writeIndent()
+	p.write("}")
+	return nil
+}
+
+func (p *Printer) VisitExpressionStmt(stmt *ast.Expression) any {
+	p.write(p.expr(stmt.Expression), ";")
+	return nil
+}
+
+func (p *Printer) VisitFunctionStmt(stmt *ast.Function) any {
+	p.write("fun ")
+	p.function(stmt)
+	return nil
+}
+
+func (p *Printer) VisitIfStmt(stmt *ast.If) any {
+	p.write("if (", p.expr(stmt.Condition), ")")
+	thenBranch := stmt.ThenBranch
+	if hasLoopElse(thenBranch) {
+		// the parser gives an else after an unbraced while in a then-branch to the if
+		thenBranch = &ast.Block{Statements: []ast.Stmt{thenBranch}}
+	}
+	p.body(thenBranch)
+	if stmt.ElseBranch == nil {
+		return nil
+	}
+
+	if _, ok := thenBranch.(*ast.Block); ok {
+		p.write(" ")
+	} else {
+		p.write("\n")
+		p.writeIndent()
+	}
+	p.write("else")
+
+	if elseIf, ok := stmt.ElseBranch.(*ast.If); ok {
+		p.write(" ")
+		p.stmt(elseIf)
+	} else {
+		p.body(stmt.ElseBranch)
+	}
+	return nil
+}
+
+// hasLoopElse reports whether the statement has a while loop with an else outside of braces
+func hasLoopElse(stmt ast.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *ast.While:
+		return stmt.ElseBranch != nil || hasLoopElse(stmt.Body)
+	case *ast.If:
+		return hasLoopElse(stmt.ThenBranch) || hasLoopElse(stmt.ElseBranch)
+	}
+	return false
+}
+
+func (p *Printer) VisitPrintStmt(stmt *ast.Print) any {
+	p.write("print ", p.exprs(stmt.Expressions), ";")
+	return nil
+}
+
+func (p *Printer) VisitEprintStmt(stmt *ast.Eprint) any {
+	p.write("eprint ", p.expr(stmt.Expression), ";")
+	return nil
+}
+
+func (p *Printer) VisitReturnStmt(stmt *ast.Return) any {
+	if stmt.Value == nil {
+		p.write("return;")
+	} else {
+		p.write("return ", p.expr(stmt.Value), ";")
+	}
+	return nil
+}
+
+func (p *Printer) VisitMultiAssignStmt(stmt *ast.MultiAssign) any {
+	p.write(p.exprs(stmt.Targets), " = ", p.exprs(stmt.Values), ";")
+	return nil
+}
+
+func (p *Printer) exprs(exprs []ast.Expr) string {
+	strs := make([]string, len(exprs))
+	for i, expr := range exprs {
+		strs[i] = p.expr(expr)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func (p *Printer) VisitDestructureStmt(stmt *ast.Destructure) any {
+	p.write("var (", params(stmt.Names), ") = ", p.expr(stmt.Initializer), ";")
+	return nil
+}
+
+func (p *Printer) VisitVarStmt(stmt *ast.Var) any {
+	if stmt.Initializer == nil {
+		p.write("var ", stmt.Name.Lexeme, ";")
+	} else {
+		p.write("var ", stmt.Name.Lexeme, " = ", p.expr(stmt.Initializer), ";")
+	}
+	return nil
+}
+
+func (p *Printer) VisitWhileStmt(stmt *ast.While) any {
+	p.write("while (", p.expr(stmt.Condition), ")")
+	body := stmt.Body
+	if stmt.Increment != nil {
+		body = &ast.Block{Statements: []ast.Stmt{body, &ast.Expression{Expression: stmt.Increment}}}
+	}
+	p.body(body)
+	if stmt.ElseBranch != nil {
+		if _, ok := body.(*ast.Block); ok {
+			p.write(" ")
+		} else {
+			p.write("\n")
+			p.writeIndent()
+		}
+		p.write("else")
+		p.body(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (p *Printer) VisitForEachStmt(stmt *ast.ForEach) any {
+	p.write("for (", stmt.Name.Lexeme, " in ", p.expr(stmt.Iterable), ")")
+	p.body(stmt.Body)
+	return nil
+}
+
+func (p *Printer) VisitAssignExpr(expr *ast.Assign) any {
+	return expr.Name.Lexeme + " = " + p.expr(expr.Value)
+}
+
+func (p *Printer) VisitBinaryExpr(expr *ast.Binary) any {
+	return p.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + p.expr(expr.Right)
+}
+
+func (p *Printer) VisitCallExpr(expr *ast.Call) any {
+	args := make([]string, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = p.expr(arg)
+		if expr.Spread[i] {
+			args[i] = "..." + args[i]
+		}
+	}
+	return p.expr(expr.Callee) + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (p *Printer) VisitGetExpr(expr *ast.Get) any {
+	if expr.Optional {
+		return p.expr(expr.Object) + "?." + expr.Name.Lexeme
+	}
+	return p.expr(expr.Object) + "." + expr.Name.Lexeme
+}
+
+func (p *Printer) VisitListExpr(expr *ast.List) any {
+	elements := make([]string, len(expr.Elements))
+	for i, element := range expr.Elements {
+		elements[i] = p.expr(element)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (p *Printer) VisitSliceExpr(expr *ast.Slice) any {
+	var start, end string
+	if expr.Start != nil {
+		start = p.expr(expr.Start)
+	}
+	if expr.End != nil {
+		end = p.expr(expr.End)
+	}
+	return p.expr(expr.Object) + "[" + start + ":" + end + "]"
+}
+
+func (p *Printer) VisitRangeExpr(expr *ast.Range) any {
+	return p.expr(expr.Start) + expr.Operator.Lexeme + p.expr(expr.End)
+}
+
+func (p *Printer) VisitSubscriptExpr(expr *ast.Subscript) any {
+	return p.expr(expr.Object) + "[" + p.expr(expr.Index) + "]"
+}
+
+func (p *Printer) VisitGroupingExpr(expr *ast.Grouping) any {
+	return "(" + p.expr(expr.Expression) + ")"
+}
+
+func (p *Printer) VisitBlockExprExpr(expr *ast.BlockExpr) any {
+	block := Printer{indent: p.indent}
+	block.write("{\n")
+	block.indent++
+	for _, statement := range expr.Statements {
+		block.writeIndent()
+		block.stmt(statement)
+		block.write("\n")
+	}
+	if expr.Value != nil {
+		block.writeIndent()
+		block.write(block.expr(expr.Value), "\n")
+	}
+	block.indent--
+	block.writeIndent()
+	block.write("}")
+	return block.builder.String()
+}
+
+func (p *Printer) VisitLambdaExpr(expr *ast.Lambda) any {
+	lambda := Printer{indent: p.indent}
+	lambda.write("fun (", params(expr.Function.Params), ") ")
+	lambda.block(expr.Function.Body)
+	return lambda.builder.String()
+}
+
+func (p *Printer) VisitLiteralExpr(expr *ast.Literal) any {
+	switch value := expr.Value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return quote(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	panic("unexpected literal value")
+}
+
+func quote(str string) string {
+	var quoted strings.Builder
+	quoted.WriteString("\"")
+	for _, r := range str {
+		switch {
+		case r == '"' || r == '\\':
+			quoted.WriteString("\\" + string(r))
+		case r == '\n':
+			quoted.WriteString("\\n")
+		case r == '\t':
+			quoted.WriteString("\\t")
+		case r == '\r':
+			quoted.WriteString("\\r")
+		case !unicode.IsPrint(r):
+			quoted.WriteString(fmt.Sprintf("\\u{%x}", r))
+		default:
+			quoted.WriteRune(r)
+		}
+	}
+	quoted.WriteString("\"")
+	return quoted.String()
+}
+
+func (p *Printer) VisitLogicalExpr(expr *ast.Logical) any {
+	return p.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + p.expr(expr.Right)
+}
+
+func (p *Printer) VisitSetExpr(expr *ast.Set) any {
+	return p.expr(expr.Object) + "." + expr.Name.Lexeme + " = " + p.expr(expr.Value)
+}
+
+func (p *Printer) VisitSuperExpr(expr *ast.Super) any {
+	return "super." + expr.Method.Lexeme
+}
+
+func (p *Printer) VisitThisExpr(expr *ast.This) any {
+	return "this"
+}
+
+func (p *Printer) VisitUnaryExpr(expr *ast.Unary) any {
+	if expr.Operator.Type == token.TYPEOF {
+		return expr.Operator.Lexeme + " " + p.expr(expr.Right)
+	}
+	return expr.Operator.Lexeme + p.expr(expr.Right)
+}
+
+func (p *Printer) VisitVariableExpr(expr *ast.Variable) any {
+	return expr.Name.Lexeme
+}
@@ -0,0 +1,130 @@
+package printer
+
+import (
+	"testing"
+
+	"github.com/michael-go/go-jsn/jsn"
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, code string) []ast.Stmt {
+	scan := scanner.New(code)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+
+	parser := parser.New(tokens)
+	return parser.Parse()
+}
+
+func astJson(t *testing.T, statements []ast.Stmt) string {
+	json, err := jsn.NewJson(statements)
+	if err != nil {
+		t.Fatalf("failed to AST convert to json: %v", err)
+	}
+	return json.Pretty()
+}
+
+func TestPrint(t *testing.T) {
+	code := `var x = 1 + 2 * 3;
+fun add(a, b) {
+  return a + b;
+}
+class Foo < Bar {
+  init(v) {
+    this.v = !v;
+  }
+  value {
+    return this.v;
+  }
+}
+while (x < 10)
+  x = add(x, "one");
+print x?.y.z;
+print [1, [2], []][-1];
+print [x[1:], x[:-1], x[:]];
+var (a, b) = [1, 2];
+a, x.y = x.y, a;
+`
+	assert.Equal(t, code, Print(parse(t, code)))
+}
+
+func TestPrintElseIfChain(t *testing.T) {
+	code := `if (a) {
+  print 1;
+} else if (b) {
+  print 2;
+} else {
+  print 3;
+}
+`
+	statements := parse(t, code)
+	output := Print(statements)
+	assert.Equal(t, code, output)
+	assert.Equal(t, astJson(t, statements), astJson(t, parse(t, output)))
+}
+
+func TestPrintWhileInThenBranch(t *testing.T) {
+	code := `if (c)
+  while (x > 0)
+    x = x - 1;
+else
+  print "else of if";
+`
+	statements := parse(t, code)
+	output := Print(statements)
+	assert.Equal(t, code, output)
+	assert.Equal(t, astJson(t, statements), astJson(t, parse(t, output)))
+
+	// a while with an else is braced to keep its else
+	code = `if (c) {
+  while (x > 0)
+    x = x - 1;
+  else
+    print "else of while";
+} else
+  print "else of if";
+`
+	statements = parse(t, code)
+	statements[0].(*ast.If).ThenBranch = statements[0].(*ast.If).ThenBranch.(*ast.Block).Statements[0]
+	output = Print(statements)
+	assert.Equal(t, code, output)
+	assert.Equal(t, astJson(t, parse(t, code)), astJson(t, parse(t, output)))
+}
+
+func TestPrintLambda(t *testing.T) {
+	code := `var f = fun (a) {
+  return a;
+};
+`
+	assert.Equal(t, code, Print(parse(t, code)))
+}
+
+func TestPrintBlockExpr(t *testing.T) {
+	code := `var x = {
+  var t = 2;
+  t * t
+};
+`
+	assert.Equal(t, code, Print(parse(t, code)))
+}
+
+func TestPrintForIn(t *testing.T) {
+	code := `for (i in 0..=n - 1) {
+  print i;
+}
+for (x in [1, 2])
+  print x;
+`
+	assert.Equal(t, code, Print(parse(t, code)))
+}
+
+func TestPrintEscapedString(t *testing.T) {
+	code := `print "say \"hi\"\n\\ \u{7}";
+`
+	assert.Equal(t, code, Print(parse(t, code)))
+}
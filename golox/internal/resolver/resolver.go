@@ -3,7 +3,6 @@ package resolver
 import (
 	"github.com/michael-go/lox/golox/internal/ast"
 	"github.com/michael-go/lox/golox/internal/globals"
-	"github.com/michael-go/lox/golox/internal/interpreter"
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
@@ -24,16 +23,70 @@ const (
 	SUBCLASS
 )
 
+// Binder receives the scope depth of every resolved local variable expression, and the
+// slot of the variable in that scope, numbering the variables of a scope in the order
+// they're declared.
+type Binder interface {
+	Resolve(expr ast.Expr, depth int, slot int)
+}
+
+// LeafBinder is a Binder that's also told about the leaf functions, which declare no
+// function, lambda or class, so no closure can capture the environment of their calls.
+type LeafBinder interface {
+	Binder
+	ResolveLeaf(function *ast.Function)
+}
+
+type Options struct {
+	// WarnShadowing reports declarations that shadow a variable from an enclosing scope
+	WarnShadowing bool
+	// WarnShadowingNatives reports locals and parameters named like a native function,
+	// see DeclareNatives
+	WarnShadowingNatives bool
+	// WarnInfiniteLoops reports `while (true)` loops with no reachable break or return
+	WarnInfiniteLoops bool
+	// WarnDivisionByZero reports dividing a literal by a literal zero, which constant
+	// folding leaves for runtime
+	WarnDivisionByZero bool
+	// WarnAssignInCondition reports an if or while condition that is an assignment, like
+	// `if (x = 1)`, which is usually a typo for ==. Parenthesizing it silences the warning.
+	WarnAssignInCondition bool
+	// TopLevelReturn allows returning a value from top-level code, ending the script
+	TopLevelReturn bool
+}
+
+// variable is a local declared in a scope
+type variable struct {
+	slot    int
+	defined bool
+}
+
 type Resolver struct {
-	interp              *interpreter.Interpreter
-	scopes              []map[string]bool
+	interp              Binder
+	scopes              []map[string]*variable
+	globals             map[string]bool
+	natives             map[string]bool
 	currentFunctionType FunctionType
 	currentClassType    ClassType
+	loopDepth           int
+	// enclosingFunctions are the functions being resolved, each set once a nested one is found
+	enclosingFunctions []bool
+
+	Options Options
 }
 
-func New(interp *interpreter.Interpreter) Resolver {
+func New(interp Binder) Resolver {
 	return Resolver{
-		interp: interp,
+		interp:  interp,
+		globals: make(map[string]bool),
+		natives: make(map[string]bool),
+	}
+}
+
+// DeclareNatives tells the resolver the names of the native functions.
+func (r *Resolver) DeclareNatives(names ...string) {
+	for _, name := range names {
+		r.natives[name] = true
 	}
 }
 
@@ -65,7 +118,7 @@ func (r *Resolver) VisitExpressionStmt(stmt *ast.Expression) any {
 }
 
 func (r *Resolver) beginScope() {
-	r.scopes = append(r.scopes, make(map[string]bool, 0))
+	r.scopes = append(r.scopes, make(map[string]*variable, 0))
 }
 
 func (r *Resolver) endScope() {
@@ -81,15 +134,40 @@ func (r *Resolver) VisitVarStmt(stmt *ast.Var) any {
 	return nil
 }
 
+func (r *Resolver) VisitDestructureStmt(stmt *ast.Destructure) any {
+	for _, name := range stmt.Names {
+		r.declare(name)
+	}
+	r.resolveExpr(stmt.Initializer)
+	for _, name := range stmt.Names {
+		r.define(name)
+	}
+	return nil
+}
+
 func (r *Resolver) declare(name token.Token) {
 	if len(r.scopes) == 0 {
+		r.globals[name.Lexeme] = true
 		return
 	}
 	scope := r.scopes[len(r.scopes)-1]
 	if _, ok := scope[name.Lexeme]; ok {
 		globals.ReportErrorAt(name, "Already a variable with this name in this scope.")
+	} else if r.Options.WarnShadowing && r.isDeclaredOutside(name.Lexeme) {
+		globals.ReportWarningAt(name, "Declaration of '"+name.Lexeme+"' shadows an outer variable.")
+	} else if r.Options.WarnShadowingNatives && r.natives[name.Lexeme] {
+		globals.ReportWarningAt(name, "Declaration of '"+name.Lexeme+"' shadows a native function.")
 	}
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &variable{slot: len(scope)}
+}
+
+func (r *Resolver) isDeclaredOutside(name string) bool {
+	for i := len(r.scopes) - 2; i >= 0; i-- {
+		if _, ok := r.scopes[i][name]; ok {
+			return true
+		}
+	}
+	return r.globals[name]
 }
 
 func (r *Resolver) define(name token.Token) {
@@ -97,14 +175,14 @@ func (r *Resolver) define(name token.Token) {
 		return
 	}
 	scope := r.scopes[len(r.scopes)-1]
-	scope[name.Lexeme] = true
+	scope[name.Lexeme].defined = true
 }
 
 func (r *Resolver) VisitVariableExpr(expr *ast.Variable) any {
 	if len(r.scopes) != 0 {
 		scope := r.scopes[len(r.scopes)-1]
 
-		if _, ok := scope[expr.Name.Lexeme]; ok && !scope[expr.Name.Lexeme] {
+		if variable, ok := scope[expr.Name.Lexeme]; ok && !variable.defined {
 			globals.ReportErrorAt(expr.Name, "Can't read local variable in its own initializer.")
 		}
 	}
@@ -115,8 +193,8 @@ func (r *Resolver) VisitVariableExpr(expr *ast.Variable) any {
 
 func (r *Resolver) resolveLocal(expr ast.Expr, name token.Token) {
 	for i := len(r.scopes) - 1; i >= 0; i-- {
-		if _, ok := r.scopes[i][name.Lexeme]; ok {
-			r.interp.Resolve(expr, len(r.scopes)-1-i)
+		if variable, ok := r.scopes[i][name.Lexeme]; ok {
+			r.interp.Resolve(expr, len(r.scopes)-1-i, variable.slot)
 			return
 		}
 	}
@@ -128,6 +206,21 @@ func (r *Resolver) VisitAssignExpr(expr *ast.Assign) any {
 	return nil
 }
 
+func (r *Resolver) VisitMultiAssignStmt(stmt *ast.MultiAssign) any {
+	for _, value := range stmt.Values {
+		r.resolveExpr(value)
+	}
+	for _, target := range stmt.Targets {
+		switch target := target.(type) {
+		case *ast.Variable:
+			r.resolveLocal(target, target.Name)
+		case *ast.Get:
+			r.resolveExpr(target.Object)
+		}
+	}
+	return nil
+}
+
 func (r *Resolver) VisitFunctionStmt(stmt *ast.Function) any {
 	r.declare(stmt.Name)
 	r.define(stmt.Name)
@@ -139,6 +232,12 @@ func (r *Resolver) VisitFunctionStmt(stmt *ast.Function) any {
 func (r *Resolver) resolveFunction(stmt *ast.Function, funcType FunctionType) any {
 	encosingFunction := r.currentFunctionType
 	r.currentFunctionType = funcType
+	enclosingLoopDepth := r.loopDepth
+	r.loopDepth = 0
+	for i := range r.enclosingFunctions {
+		r.enclosingFunctions[i] = true
+	}
+	r.enclosingFunctions = append(r.enclosingFunctions, false)
 
 	r.beginScope()
 	for _, param := range stmt.Params {
@@ -148,11 +247,19 @@ func (r *Resolver) resolveFunction(stmt *ast.Function, funcType FunctionType) an
 	r.Resolve(stmt.Body)
 	r.endScope()
 
+	hasNested := r.enclosingFunctions[len(r.enclosingFunctions)-1]
+	r.enclosingFunctions = r.enclosingFunctions[:len(r.enclosingFunctions)-1]
+	if binder, ok := r.interp.(LeafBinder); ok && !hasNested {
+		binder.ResolveLeaf(stmt)
+	}
+
 	r.currentFunctionType = encosingFunction
+	r.loopDepth = enclosingLoopDepth
 	return nil
 }
 
 func (r *Resolver) VisitIfStmt(stmt *ast.If) any {
+	r.checkCondition(stmt.Condition)
 	r.resolveExpr(stmt.Condition)
 	r.resolveStmt(stmt.ThenBranch)
 	if stmt.ElseBranch != nil {
@@ -162,12 +269,19 @@ func (r *Resolver) VisitIfStmt(stmt *ast.If) any {
 }
 
 func (r *Resolver) VisitPrintStmt(stmt *ast.Print) any {
+	for _, expr := range stmt.Expressions {
+		r.resolveExpr(expr)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitEprintStmt(stmt *ast.Eprint) any {
 	r.resolveExpr(stmt.Expression)
 	return nil
 }
 
 func (r *Resolver) VisitReturnStmt(stmt *ast.Return) any {
-	if r.currentFunctionType == NOT_FUNC {
+	if r.currentFunctionType == NOT_FUNC && !r.Options.TopLevelReturn {
 		globals.ReportErrorAt(stmt.Keyword, "Can't return from top-level code.")
 	}
 
@@ -181,12 +295,91 @@ func (r *Resolver) VisitReturnStmt(stmt *ast.Return) any {
 }
 
 func (r *Resolver) VisitWhileStmt(stmt *ast.While) any {
+	if r.Options.WarnInfiniteLoops && isLiteral(stmt.Condition, true) && !canExitLoop(stmt.Body, false) {
+		globals.ReportWarningAt(stmt.Keyword, "Loop has no reachable 'break' or 'return' and never ends.")
+	}
+
+	r.checkCondition(stmt.Condition)
 	r.resolveExpr(stmt.Condition)
+	r.loopDepth++
+	r.resolveStmt(stmt.Body)
+	r.loopDepth--
+	if stmt.Increment != nil {
+		r.resolveExpr(stmt.Increment)
+	}
+	// the else branch isn't part of the loop, a break in it exits an enclosing loop
+	if stmt.ElseBranch != nil {
+		r.resolveStmt(stmt.ElseBranch)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitForEachStmt(stmt *ast.ForEach) any {
+	r.resolveExpr(stmt.Iterable)
+	// the loop variable is in a scope of its own, declared anew for each iteration
+	r.beginScope()
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.loopDepth++
 	r.resolveStmt(stmt.Body)
+	r.loopDepth--
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) checkCondition(condition ast.Expr) {
+	if assign, ok := condition.(*ast.Assign); ok && r.Options.WarnAssignInCondition {
+		globals.ReportWarningAt(assign.Name, "Assignment used as a condition; did you mean '=='?")
+	}
+}
+
+func isLiteral(expr ast.Expr, value any) bool {
+	literal, ok := expr.(*ast.Literal)
+	return ok && literal.Value == value
+}
+
+// canExitLoop reports whether the loop body has a break or return that can leave the loop.
+// A break inside a nested loop only leaves that loop, and nested functions are not scanned.
+func canExitLoop(stmt ast.Stmt, nested bool) bool {
+	switch stmt := stmt.(type) {
+	case *ast.Break:
+		return !nested
+	case *ast.Return:
+		return true
+	case *ast.Block:
+		for _, statement := range stmt.Statements {
+			if canExitLoop(statement, nested) {
+				return true
+			}
+		}
+	case *ast.If:
+		if isLiteral(stmt.Condition, false) {
+			return stmt.ElseBranch != nil && canExitLoop(stmt.ElseBranch, nested)
+		}
+		if canExitLoop(stmt.ThenBranch, nested) {
+			return true
+		}
+		return stmt.ElseBranch != nil && !isLiteral(stmt.Condition, true) && canExitLoop(stmt.ElseBranch, nested)
+	case *ast.While:
+		return !isLiteral(stmt.Condition, false) && canExitLoop(stmt.Body, true)
+	}
+	return false
+}
+
+func (r *Resolver) VisitBreakStmt(stmt *ast.Break) any {
+	if r.loopDepth == 0 {
+		globals.ReportErrorAt(stmt.Keyword, "Can't use 'break' outside of a loop.")
+	}
 	return nil
 }
 
 func (r *Resolver) VisitBinaryExpr(expr *ast.Binary) any {
+	if r.Options.WarnDivisionByZero && (expr.Operator.Type == token.SLASH || expr.Operator.Type == token.DIV) {
+		if _, ok := expr.Left.(*ast.Literal); ok && isLiteral(expr.Right, 0.0) {
+			globals.ReportWarningAt(expr.Operator, "Division by zero in constant expression.")
+		}
+	}
+
 	r.resolveExpr(expr.Left)
 	r.resolveExpr(expr.Right)
 	return nil
@@ -206,6 +399,21 @@ func (r *Resolver) VisitGroupingExpr(expr *ast.Grouping) any {
 	return nil
 }
 
+func (r *Resolver) VisitBlockExprExpr(expr *ast.BlockExpr) any {
+	r.beginScope()
+	r.Resolve(expr.Statements)
+	if expr.Value != nil {
+		r.resolveExpr(expr.Value)
+	}
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) VisitLambdaExpr(expr *ast.Lambda) any {
+	r.resolveFunction(expr.Function, FUNCTION)
+	return nil
+}
+
 func (r *Resolver) VisitLiteralExpr(expr *ast.Literal) any {
 	return nil
 }
@@ -239,11 +447,11 @@ func (r *Resolver) VisitClassStmt(stmt *ast.Class) any {
 
 	if stmt.Superclass != nil {
 		r.beginScope()
-		r.scopes[len(r.scopes)-1]["super"] = true
+		r.scopes[len(r.scopes)-1]["super"] = &variable{slot: 0, defined: true}
 	}
 
 	r.beginScope()
-	r.scopes[len(r.scopes)-1]["this"] = true
+	r.scopes[len(r.scopes)-1]["this"] = &variable{slot: 0, defined: true}
 
 	for _, method := range stmt.Methods {
 		declaration := METHOD
@@ -268,6 +476,36 @@ func (r *Resolver) VisitGetExpr(expr *ast.Get) any {
 	return nil
 }
 
+func (r *Resolver) VisitListExpr(expr *ast.List) any {
+	for _, element := range expr.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitSliceExpr(expr *ast.Slice) any {
+	r.resolveExpr(expr.Object)
+	if expr.Start != nil {
+		r.resolveExpr(expr.Start)
+	}
+	if expr.End != nil {
+		r.resolveExpr(expr.End)
+	}
+	return nil
+}
+
+func (r *Resolver) VisitRangeExpr(expr *ast.Range) any {
+	r.resolveExpr(expr.Start)
+	r.resolveExpr(expr.End)
+	return nil
+}
+
+func (r *Resolver) VisitSubscriptExpr(expr *ast.Subscript) any {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+	return nil
+}
+
 func (r *Resolver) VisitSetExpr(expr *ast.Set) any {
 	r.resolveExpr(expr.Value)
 	r.resolveExpr(expr.Object)
@@ -0,0 +1,280 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/globals"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/stretchr/testify/assert"
+)
+
+type bindings map[ast.Expr]int
+
+func (b bindings) Resolve(expr ast.Expr, depth int, slot int) {
+	b[expr] = depth
+}
+
+// leafBindings records the names of the leaf functions
+type leafBindings struct {
+	bindings
+	leaves []string
+}
+
+func (b *leafBindings) ResolveLeaf(function *ast.Function) {
+	b.leaves = append(b.leaves, function.Name.Lexeme)
+}
+
+// resolveErrors resolves the code and returns the reported errors and warnings
+func resolveErrors(t *testing.T, code string, options Options) []string {
+	scan := scanner.New(code)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+
+	parser := parser.New(tokens)
+	statements := parser.Parse()
+
+	restore := globals.Capture()
+	defer restore()
+
+	resolver := New(bindings{})
+	resolver.Options = options
+	resolver.DeclareNatives("clock")
+	resolver.Resolve(statements)
+	return restore()
+}
+
+func TestShadowingWarning(t *testing.T) {
+	code := `
+		var x = 1;
+		{
+			var x = 2;
+		}`
+	assert.Nil(t, resolveErrors(t, code, Options{}))
+	assert.Equal(t, []string{"[line 4] Warning at 'x': Declaration of 'x' shadows an outer variable."}, resolveErrors(t, code, Options{WarnShadowing: true}))
+
+	code = `
+		fun f(a) {
+			{
+				var a = 2;
+			}
+		}`
+	assert.Equal(t, []string{"[line 4] Warning at 'a': Declaration of 'a' shadows an outer variable."}, resolveErrors(t, code, Options{WarnShadowing: true}))
+}
+
+func TestNoShadowingWarningInSiblingScopes(t *testing.T) {
+	code := `
+		{
+			var x = 1;
+		}
+		{
+			var x = 2;
+		}`
+	assert.Nil(t, resolveErrors(t, code, Options{WarnShadowing: true}))
+}
+
+func TestRedeclarationError(t *testing.T) {
+	code := `
+		{
+			var x = 1;
+			var x = 2;
+		}`
+	assert.Equal(t, []string{"[line 4] Error at 'x': Already a variable with this name in this scope."}, resolveErrors(t, code, Options{WarnShadowing: true}))
+}
+
+// resolveHadError resolves the code and returns whether it failed
+func resolveHadError(t *testing.T, code string, options Options) bool {
+	scan := scanner.New(code)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		t.Fatalf("failed to scan tokens: %v", err)
+	}
+
+	parser := parser.New(tokens)
+	statements := parser.Parse()
+
+	restore := globals.Capture()
+	defer restore()
+
+	resolver := New(bindings{})
+	resolver.Options = options
+	resolver.Resolve(statements)
+	return globals.HadError
+}
+
+func TestWarningsDontFail(t *testing.T) {
+	shadowing := `
+		var x = 1;
+		{
+			var x = 2;
+		}`
+	assert.False(t, resolveHadError(t, shadowing, Options{WarnShadowing: true}))
+	assert.True(t, resolveHadError(t, `{ var x = 1; var x = 2; }`, Options{}))
+
+	globals.WarningsAsErrors = true
+	defer func() { globals.WarningsAsErrors = false }()
+	assert.True(t, resolveHadError(t, shadowing, Options{WarnShadowing: true}))
+}
+
+func TestInfiniteLoopWarning(t *testing.T) {
+	code := `
+		while (true) {}`
+	assert.Nil(t, resolveErrors(t, code, Options{}))
+	assert.Equal(t, []string{"[line 2] Warning at 'while': Loop has no reachable 'break' or 'return' and never ends."}, resolveErrors(t, code, Options{WarnInfiniteLoops: true}))
+
+	code = `
+		for (;;) {
+			while (true) {
+				break;
+			}
+			if (false) break;
+		}`
+	assert.Equal(t, []string{"[line 2] Warning at 'for': Loop has no reachable 'break' or 'return' and never ends."}, resolveErrors(t, code, Options{WarnInfiniteLoops: true}))
+}
+
+func TestNoInfiniteLoopWarning(t *testing.T) {
+	code := `
+		var x = false;
+		while (true) {
+			if (x) break;
+		}
+		fun f() {
+			while (true) {
+				{
+					return 1;
+				}
+			}
+		}
+		while (x) {}`
+	assert.Nil(t, resolveErrors(t, code, Options{WarnInfiniteLoops: true}))
+}
+
+func TestAssignInConditionWarning(t *testing.T) {
+	code := `
+		var x; var y;
+		fun f() { return nil; }
+		if (x = 1) {}
+		while (y = f()) {}
+		if (x == 1) {}
+		if ((x = 1)) {}`
+	assert.Nil(t, resolveErrors(t, code, Options{}))
+	assert.Equal(t, []string{
+		"[line 4] Warning at 'x': Assignment used as a condition; did you mean '=='?",
+		"[line 5] Warning at 'y': Assignment used as a condition; did you mean '=='?",
+	}, resolveErrors(t, code, Options{WarnAssignInCondition: true}))
+}
+
+func TestBreakOutsideLoop(t *testing.T) {
+	code := `
+		break;
+		while (true) {
+			fun f() {
+				break;
+			}
+			break;
+		}`
+	assert.Equal(t, []string{
+		"[line 2] Error at 'break': Can't use 'break' outside of a loop.",
+		"[line 5] Error at 'break': Can't use 'break' outside of a loop.",
+	}, resolveErrors(t, code, Options{}))
+}
+
+func TestShadowingNativeWarning(t *testing.T) {
+	code := `
+		fun f(clock) {
+			var x = clock;
+		}
+		fun g() {
+			var clock = 1;
+		}`
+	assert.Nil(t, resolveErrors(t, code, Options{}))
+	assert.Equal(t, []string{
+		"[line 2] Warning at 'clock': Declaration of 'clock' shadows a native function.",
+		"[line 6] Warning at 'clock': Declaration of 'clock' shadows a native function.",
+	}, resolveErrors(t, code, Options{WarnShadowingNatives: true}))
+
+	code = `
+		var clock = 1;
+		fun f(x) {
+			var y = x;
+		}`
+	assert.Nil(t, resolveErrors(t, code, Options{WarnShadowingNatives: true}))
+}
+
+func TestResolveLeaf(t *testing.T) {
+	scan := scanner.New(`
+		fun leaf(a) { var b = a; { var c = b; } return c; }
+		fun outer() {
+			fun inner() { return 1; }
+			return inner;
+		}
+		fun withLambda() { return fun () {}; }
+		fun withClass() {
+			class A { method() {} }
+		}
+		class B { method() { return 1; } }`)
+	tokens, err := scan.ScanTokens()
+	assert.Nil(t, err)
+	parser := parser.New(tokens)
+	statements := parser.Parse()
+
+	binder := &leafBindings{bindings: bindings{}}
+	resolver := New(binder)
+	resolver.Resolve(statements)
+	assert.Equal(t, []string{"leaf", "inner", "", "method", "method"}, binder.leaves)
+}
+
+// variableCollector collects the variable expressions with a given name
+type variableCollector struct {
+	ast.BaseVisitor
+	name      string
+	variables []*ast.Variable
+}
+
+func (c *variableCollector) VisitVariableExpr(expr *ast.Variable) any {
+	if expr.Name.Lexeme == c.name {
+		c.variables = append(c.variables, expr)
+	}
+	return nil
+}
+
+func TestDeeplyNestedLocal(t *testing.T) {
+	scan := scanner.New(`
+		var x = "global";
+		fun f() {
+			var x = "local";
+			{ { {
+				fun g() {
+					{ return x; }
+				}
+				return g() + x;
+			} } }
+		}
+		print x;`)
+	tokens, err := scan.ScanTokens()
+	assert.Nil(t, err)
+	parser := parser.New(tokens)
+	statements := parser.Parse()
+
+	binder := bindings{}
+	resolver := New(binder)
+	resolver.Resolve(statements)
+
+	collector := &variableCollector{name: "x"}
+	collector.Self = collector
+	collector.Walk(statements)
+	assert.Len(t, collector.variables, 3)
+
+	// the same node pointers the parser produced are the resolved keys
+	depth, ok := binder[collector.variables[0]]
+	assert.True(t, ok)
+	assert.Equal(t, 5, depth)
+	depth, ok = binder[collector.variables[1]]
+	assert.True(t, ok)
+	assert.Equal(t, 3, depth)
+	_, ok = binder[collector.variables[2]]
+	assert.False(t, ok, "a global isn't resolved as a local")
+}
@@ -6,14 +6,23 @@ func isDigit(r rune) bool {
 	return '0' <= r && r <= '9'
 }
 
+func isHexDigit(r rune) bool {
+	return isDigit(r) || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
 var keywords = map[string]token.Type{
 	"and":    token.AND,
+	"break":  token.BREAK,
 	"class":  token.CLASS,
+	"div":    token.DIV,
+	"elif":   token.ELIF,
 	"else":   token.ELSE,
+	"eprint": token.EPRINT,
 	"false":  token.FALSE,
 	"for":    token.FOR,
 	"fun":    token.FUN,
 	"if":     token.IF,
+	"in":     token.IN,
 	"nil":    token.NIL,
 	"or":     token.OR,
 	"print":  token.PRINT,
@@ -21,6 +30,7 @@ var keywords = map[string]token.Type{
 	"super":  token.SUPER,
 	"this":   token.THIS,
 	"true":   token.TRUE,
+	"typeof": token.TYPEOF,
 	"var":    token.VAR,
 	"while":  token.WHILE,
 }
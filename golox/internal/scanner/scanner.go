@@ -1,24 +1,78 @@
 package scanner
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/token"
 )
 
+type Options struct {
+	// Keywords are merged over the default keywords, e.g. to localize them
+	Keywords map[string]token.Type
+}
+
+// ScanError is a lexical error, which is also reported when it's found.
+type ScanError struct {
+	Line    int
+	Message string
+	// File is the source file set by a #line directive, if any
+	File string
+}
+
+func (e ScanError) Error() string {
+	return fmt.Sprintf("[%s] Error: %s", globals.Location(e.File, e.Line), e.Message)
+}
+
+// ScanErrors are all the errors found by ScanTokens.
+type ScanErrors []ScanError
+
+func (e ScanErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
 type Scanner struct {
-	source string
-	tokens []token.Token
+	source   string
+	tokens   []token.Token
+	keywords map[string]token.Type
+	errors   ScanErrors
 
 	start   int
 	current int
 	line    int
+	file    string
+	// newline is set when a line break was scanned since the last token
+	newline bool
+	// interned maps the string literals and identifiers scanned so far to a single copy
+	interned map[string]string
 }
 
-func New(source string) Scanner {
-	s := Scanner{source: source, line: 1}
+var lineDirective = regexp.MustCompile(`^line[ \t]+(\d+)(?:[ \t]+"([^"]*)")?[ \t]*\r?$`)
+
+func New(source string, opts ...Options) Scanner {
+	s := Scanner{source: source, line: 1, keywords: keywords}
+	for _, opt := range opts {
+		if opt.Keywords == nil {
+			continue
+		}
+		merged := make(map[string]token.Type, len(s.keywords)+len(opt.Keywords))
+		for text, tokenType := range s.keywords {
+			merged[text] = tokenType
+		}
+		for text, tokenType := range opt.Keywords {
+			merged[text] = tokenType
+		}
+		s.keywords = merged
+	}
 	return s
 }
 
@@ -31,9 +85,18 @@ func (s *Scanner) ScanTokens() ([]token.Token, error) {
 	s.start = s.current
 	s.addToken(token.EOF)
 
+	// the tokens are still returned on errors, so a parser can report syntax errors too
+	if s.errors != nil {
+		return s.tokens, s.errors
+	}
 	return s.tokens, nil
 }
 
+func (s *Scanner) error(message string) {
+	s.errors = append(s.errors, ScanError{Line: s.line, Message: message, File: s.file})
+	globals.ReportError(s.file, s.line, "", message)
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -49,10 +112,46 @@ func (s *Scanner) scanToken() {
 		s.addToken(token.LEFT_BRACE)
 	case rune('}'):
 		s.addToken(token.RIGHT_BRACE)
+	case rune('['):
+		s.addToken(token.LEFT_BRACKET)
+	case rune(']'):
+		s.addToken(token.RIGHT_BRACKET)
+	case rune(':'):
+		s.addToken(token.COLON)
 	case rune(','):
 		s.addToken(token.COMMA)
 	case rune('.'):
-		s.addToken(token.DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.advance()
+			s.advance()
+			s.addToken(token.DOT_DOT_DOT)
+		} else if s.match('.') {
+			if s.match('=') {
+				s.addToken(token.DOT_DOT_EQUAL)
+			} else {
+				s.addToken(token.DOT_DOT)
+			}
+		} else {
+			s.addToken(token.DOT)
+		}
+	case rune('?'):
+		if s.match('.') {
+			s.addToken(token.QUESTION_DOT)
+		} else {
+			s.error("Unexpected character.")
+		}
+	case rune('&'):
+		if s.match('&') {
+			s.addToken(token.AND)
+		} else {
+			s.error("Unexpected character.")
+		}
+	case rune('|'):
+		if s.match('|') {
+			s.addToken(token.OR)
+		} else {
+			s.error("Unexpected character.")
+		}
 	case rune('-'):
 		s.addToken(token.MINUS)
 	case rune('+'):
@@ -98,15 +197,32 @@ func (s *Scanner) scanToken() {
 	case rune('\t'):
 	case rune('\n'):
 		s.line++
+		s.newline = true
 	case rune('"'):
 		s.string()
+	case rune('\''):
+		s.char()
+	case rune('#'):
+		// a shebang line makes a script executable
+		if s.start == 0 && s.peek() == '!' {
+			for !s.isAtEnd() && s.peek() != '\n' {
+				s.advance()
+			}
+			break
+		}
+		if s.start == 0 || s.source[s.start-1] == '\n' {
+			if s.directive() {
+				break
+			}
+		}
+		s.error("Unexpected character.")
 	default:
 		if isDigit(r) {
 			s.number()
 		} else if isAlpha(r) {
 			s.identifier()
 		} else {
-			globals.ReportError(s.line, "", "Unexpected character.")
+			s.error("Unexpected character.")
 		}
 	}
 }
@@ -122,8 +238,12 @@ func (s *Scanner) addToken(t token.Type) {
 }
 
 func (s *Scanner) addTokenLiteral(tokenType token.Type, literal any) {
-	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, token.Token{Type: tokenType, Lexeme: text, Literal: literal, Line: s.line})
+	s.addTokenLexeme(tokenType, s.source[s.start:s.current], literal)
+}
+
+func (s *Scanner) addTokenLexeme(tokenType token.Type, lexeme string, literal any) {
+	s.tokens = append(s.tokens, token.Token{Type: tokenType, Lexeme: lexeme, Literal: literal, Line: s.line, File: s.file, NewlineBefore: s.newline})
+	s.newline = false
 }
 
 func (s *Scanner) match(expected rune) bool {
@@ -159,22 +279,154 @@ func (s *Scanner) peekNext() rune {
 }
 
 func (s *Scanner) string() {
+	var value strings.Builder
 	for !s.isAtEnd() && s.peek() != '"' {
-		if s.peek() == '\n' {
+		r := s.advance()
+		if r == '\n' {
 			s.line++
 		}
-		s.advance()
+		if r == '\\' && !s.isAtEnd() {
+			if decoded, ok := s.escape(); ok {
+				value.WriteRune(decoded)
+			}
+			continue
+		}
+		value.WriteRune(r)
 	}
 
 	if s.isAtEnd() {
-		globals.ReportError(s.line, "", "Unterminated string.")
+		s.error("Unterminated string.")
 		return
 	}
 
 	s.advance()
 
-	value := s.source[s.start+1 : s.current-1]
-	s.addTokenLiteral(token.STRING, value)
+	s.addTokenLiteral(token.STRING, s.intern(value.String()))
+}
+
+// char scans a character literal like 'a' or '\n', which is a one character string
+func (s *Scanner) char() {
+	var value []rune
+	valid := true
+	for !s.isAtEnd() && s.peek() != '\'' && s.peek() != '\n' {
+		r := s.advance()
+		if r == '\\' && !s.isAtEnd() {
+			decoded, ok := s.escape()
+			value = append(value, decoded)
+			valid = valid && ok
+			continue
+		}
+		value = append(value, r)
+	}
+
+	if s.isAtEnd() || s.peek() == '\n' {
+		s.error("Unterminated character literal.")
+		return
+	}
+
+	s.advance()
+
+	if !valid {
+		return
+	}
+	if len(value) != 1 {
+		s.error("Character literal must contain exactly one character.")
+		return
+	}
+	s.addTokenLiteral(token.STRING, s.intern(string(value)))
+}
+
+// decodeEscape decodes a single character escape sequence from the character following
+// the backslash. It's used by both string and character literals so they accept the same escapes.
+func decodeEscape(r rune) (rune, error) {
+	switch r {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '"', '\'', '\\':
+		return r, nil
+	}
+	return 0, errors.New("Invalid escape sequence.")
+}
+
+// escape decodes the escape sequence following a backslash, reporting an error
+// for an invalid one.
+func (s *Scanner) escape() (rune, bool) {
+	r := s.advance()
+	switch r {
+	case 'x':
+		start := s.current
+		for i := 0; i < 2 && isHexDigit(s.peek()); i++ {
+			s.advance()
+		}
+		if s.current-start != 2 {
+			s.error("Invalid hex escape sequence.")
+			return 0, false
+		}
+		value, _ := strconv.ParseUint(s.source[start:s.current], 16, 8)
+		return rune(value), true
+	case 'u':
+		if !s.match('{') {
+			s.error("Expect '{' after '\\u'.")
+			return 0, false
+		}
+		start := s.current
+		for isHexDigit(s.peek()) {
+			s.advance()
+		}
+		digits := s.source[start:s.current]
+		if !s.match('}') || len(digits) == 0 || len(digits) > 6 {
+			s.error("Invalid unicode escape sequence.")
+			return 0, false
+		}
+		value, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(value)) {
+			s.error("Invalid unicode code point.")
+			return 0, false
+		}
+		return rune(value), true
+	}
+
+	decoded, err := decodeEscape(r)
+	if err != nil {
+		if r == '\n' {
+			s.line++
+		}
+		s.error(err.Error())
+		return 0, false
+	}
+	return decoded, true
+}
+
+// directive handles a `#line N "file"` directive, which makes the following line be
+// reported as line N of file. It returns false if the line isn't a directive.
+func (s *Scanner) directive() bool {
+	end := strings.IndexByte(s.source[s.current:], '\n')
+	if end < 0 {
+		end = len(s.source) - s.current
+	}
+	text := s.source[s.current : s.current+end]
+	if !strings.HasPrefix(text, "line") {
+		return false
+	}
+
+	s.current += end
+	match := lineDirective.FindStringSubmatch(text)
+	if match == nil {
+		s.error("Invalid '#line' directive.")
+		return true
+	}
+
+	line, _ := strconv.Atoi(match[1])
+	// the newline ending the directive is still to be scanned and will bump the line
+	s.line = line - 1
+	if match[2] != "" {
+		s.file = match[2]
+	}
+	return true
 }
 
 func (s *Scanner) number() {
@@ -199,9 +451,23 @@ func (s *Scanner) identifier() {
 	}
 
 	text := s.source[s.start:s.current]
-	tokenType, exists := keywords[text]
+	tokenType, exists := s.keywords[text]
 	if !exists {
-		tokenType = token.IDENTIFIER
+		s.addTokenLexeme(token.IDENTIFIER, s.intern(text), nil)
+		return
 	}
 	s.addToken(tokenType)
 }
+
+// intern returns the first copy of an identical string scanned, so equal strings share
+// their storage and compare faster
+func (s *Scanner) intern(str string) string {
+	if interned, ok := s.interned[str]; ok {
+		return interned
+	}
+	if s.interned == nil {
+		s.interned = make(map[string]string)
+	}
+	s.interned[str] = str
+	return str
+}
@@ -2,7 +2,10 @@ package scanner
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"unsafe"
 
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/token"
@@ -81,12 +84,219 @@ EOF  <nil>
 }
 
 func TestErrors(t *testing.T) {
-	scanner := New("$?x")
+	scanner := New("$?x\n\"open")
 	tokens, err := scanner.ScanTokens()
-	assert.Nil(t, err)
+	assert.Equal(t, ScanErrors{
+		{Line: 1, Message: "Unexpected character."},
+		{Line: 1, Message: "Unexpected character."},
+		{Line: 2, Message: "Unterminated string."},
+	}, err)
+	assert.Equal(t, "[line 1] Error: Unexpected character.\n[line 1] Error: Unexpected character.\n[line 2] Error: Unterminated string.", err.Error())
 	assert.True(t, globals.HadError)
 	assert.Equal(t, []token.Token{
 		{Type: token.IDENTIFIER, Lexeme: "x", Line: 1},
+		{Type: token.EOF, Line: 2, NewlineBefore: true},
+	}, tokens)
+}
+
+func TestLogicalOperatorSynonyms(t *testing.T) {
+	scanner := New("a && b || c")
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+	assert.Equal(t, []token.Token{
+		{Type: token.IDENTIFIER, Lexeme: "a", Line: 1},
+		{Type: token.AND, Lexeme: "&&", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "b", Line: 1},
+		{Type: token.OR, Lexeme: "||", Line: 1},
+		{Type: token.IDENTIFIER, Lexeme: "c", Line: 1},
 		{Type: token.EOF, Line: 1},
 	}, tokens)
+
+	scanner = New("a & b | c")
+	_, err = scanner.ScanTokens()
+	assert.Equal(t, ScanErrors{
+		{Line: 1, Message: "Unexpected character."},
+		{Line: 1, Message: "Unexpected character."},
+	}, err)
+}
+
+func scanStringLiteral(t *testing.T, code string) any {
+	scanner := New(code)
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+	assert.Equal(t, token.STRING, tokens[0].Type)
+	return tokens[0].Literal
+}
+
+func TestEscapes(t *testing.T) {
+	assert.Equal(t, "a\n\tb\"c\\", scanStringLiteral(t, `"a\n\tb\"c\\"`))
+	assert.Equal(t, "A", scanStringLiteral(t, `"\x41"`))
+	assert.Equal(t, "ÿ", scanStringLiteral(t, `"\xff"`))
+	assert.Equal(t, "é", scanStringLiteral(t, `"\u{e9}"`))
+	assert.Equal(t, "😀", scanStringLiteral(t, `"\u{1F600}"`))
+	assert.Equal(t, "[x] y", scanStringLiteral(t, `"[\x78] \u{79}"`))
+}
+
+func TestCharLiterals(t *testing.T) {
+	assert.Equal(t, "a", scanStringLiteral(t, `'a'`))
+	assert.Equal(t, "é", scanStringLiteral(t, `'é'`))
+	assert.Equal(t, "'", scanStringLiteral(t, `'\''`))
+	// escapes decode the same in character and string literals
+	for _, escape := range []string{`\n`, `\t`, `\r`, `\"`, `\'`, `\\`, `\x41`, `\xff`, `\u{e9}`, `\u{1F600}`} {
+		assert.Equal(t, scanStringLiteral(t, `"`+escape+`"`), scanStringLiteral(t, `'`+escape+`'`), escape)
+	}
+}
+
+func TestMalformedCharLiterals(t *testing.T) {
+	for code, expected := range map[string]string{
+		`'ab'`:   "[line 1] Error: Character literal must contain exactly one character.",
+		`''`:     "[line 1] Error: Character literal must contain exactly one character.",
+		`'a`:     "[line 1] Error: Unterminated character literal.",
+		"'a\n'":  "[line 1] Error: Unterminated character literal.",
+		`'\q'`:   "[line 1] Error: Invalid escape sequence.",
+		`'\x4g'`: "[line 1] Error: Invalid hex escape sequence.",
+	} {
+		restore := globals.Capture()
+		scanner := New(code)
+		scanner.ScanTokens()
+		assert.Equal(t, expected, restore()[0], code)
+	}
+}
+
+func TestMalformedEscapes(t *testing.T) {
+	var errors []string
+	for _, code := range []string{`"\x4g"`, `"\u{110000}"`, `"\u{}"`, `"\u41"`, `"\q"`} {
+		restore := globals.Capture()
+		scanner := New(code)
+		tokens, _ := scanner.ScanTokens()
+		errors = restore()
+		assert.Len(t, errors, 1, code)
+		assert.Equal(t, token.STRING, tokens[0].Type, code)
+	}
+	assert.Equal(t, "[line 1] Error: Invalid escape sequence.", errors[0])
+}
+
+func TestNewlineBefore(t *testing.T) {
+	scanner := New("a b\n// comment\n\n  c \"d\ne\" f\n")
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+
+	var newlines []bool
+	for _, token := range tokens {
+		newlines = append(newlines, token.NewlineBefore)
+	}
+	assert.Equal(t, []bool{false, false, true, false, false, true}, newlines)
+}
+
+func TestShebang(t *testing.T) {
+	scanner := New("#!/usr/bin/env golox\nprint 1;")
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+	assert.Equal(t, "PRINT print <nil>\nNUMBER 1 1\nSEMICOLON ; <nil>\nEOF  <nil>\n", tokensString(tokens))
+	assert.Equal(t, 2, tokens[0].Line)
+
+	scanner = New("print 1;\n#!/usr/bin/env golox\n")
+	_, err = scanner.ScanTokens()
+	assert.Equal(t, ScanErrors{{Line: 2, Message: "Unexpected character."}}, err)
+}
+
+func TestLineDirective(t *testing.T) {
+	scanner := New("print 1;\n#line 100\nprint 2;\nprint 3;\n#line 7 \"gen.lox\"\nx")
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+	assert.Equal(t, []token.Token{
+		{Type: token.PRINT, Lexeme: "print", Line: 1},
+		{Type: token.NUMBER, Lexeme: "1", Literal: 1.0, Line: 1},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 1},
+		{Type: token.PRINT, Lexeme: "print", Line: 100, NewlineBefore: true},
+		{Type: token.NUMBER, Lexeme: "2", Literal: 2.0, Line: 100},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 100},
+		{Type: token.PRINT, Lexeme: "print", Line: 101, NewlineBefore: true},
+		{Type: token.NUMBER, Lexeme: "3", Literal: 3.0, Line: 101},
+		{Type: token.SEMICOLON, Lexeme: ";", Line: 101},
+		{Type: token.IDENTIFIER, Lexeme: "x", Line: 7, File: "gen.lox", NewlineBefore: true},
+		{Type: token.EOF, Line: 7, File: "gen.lox"},
+	}, tokens)
+}
+
+func TestInvalidLineDirective(t *testing.T) {
+	restore := globals.Capture()
+	defer restore()
+
+	scanner := New("#line x\nprint 1; #line 5\n")
+	scanner.ScanTokens()
+	assert.Equal(t, []string{"[line 1] Error: Invalid '#line' directive.", "[line 2] Error: Unexpected character."}, restore())
+}
+
+func TestLineDirectiveErrors(t *testing.T) {
+	restore := globals.Capture()
+	defer restore()
+
+	scanner := New("#line 10 \"lib.lox\"\nprint @;")
+	_, err := scanner.ScanTokens()
+	assert.Equal(t, "[lib.lox:10] Error: Unexpected character.", err.Error())
+	assert.Equal(t, []string{"[lib.lox:10] Error: Unexpected character."}, restore())
+}
+
+func TestLocalizedKeywords(t *testing.T) {
+	spanish := map[string]token.Type{
+		"si":       token.IF,
+		"sino":     token.ELSE,
+		"mientras": token.WHILE,
+		"imprimir": token.PRINT,
+		"verdad":   token.TRUE,
+	}
+	scanner := New(`mientras (verdad) si (x) imprimir x; sino print nil;`, Options{Keywords: spanish})
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+
+	var types []token.Type
+	for _, tok := range tokens {
+		types = append(types, tok.Type)
+	}
+	assert.Equal(t, []token.Type{
+		token.WHILE, token.LEFT_PAREN, token.TRUE, token.RIGHT_PAREN,
+		token.IF, token.LEFT_PAREN, token.IDENTIFIER, token.RIGHT_PAREN,
+		token.PRINT, token.IDENTIFIER, token.SEMICOLON,
+		token.ELSE, token.PRINT, token.NIL, token.SEMICOLON,
+		token.EOF,
+	}, types)
+
+	scanner = New(`si`)
+	tokens, _ = scanner.ScanTokens()
+	assert.Equal(t, token.IDENTIFIER, tokens[0].Type)
+}
+
+// stringData returns the address of the string's bytes
+func stringData(str string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&str)).Data
+}
+
+func TestInternedStrings(t *testing.T) {
+	scanner := New(`var a = "hello"; var b = "hello"; print a + "hel" + "lo";`)
+	tokens, err := scanner.ScanTokens()
+	assert.Nil(t, err)
+
+	var literals []string
+	var identifiers []string
+	for _, tok := range tokens {
+		if tok.Type == token.STRING {
+			literals = append(literals, tok.Literal.(string))
+		} else if tok.Type == token.IDENTIFIER && tok.Lexeme == "a" {
+			identifiers = append(identifiers, tok.Lexeme)
+		}
+	}
+	assert.Equal(t, []string{"hello", "hello", "hel", "lo"}, literals)
+	assert.Equal(t, stringData(literals[0]), stringData(literals[1]))
+	assert.NotEqual(t, stringData(literals[0]), stringData(literals[2]))
+	assert.Equal(t, stringData(identifiers[0]), stringData(identifiers[1]))
+}
+
+func BenchmarkScanStrings(b *testing.B) {
+	source := strings.Repeat(`print "some repeated string" + "another one" + name;`+"\n", 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		scanner := New(source)
+		scanner.ScanTokens()
+	}
 }
@@ -17,6 +17,9 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	LEFT_BRACKET
+	RIGHT_BRACKET
+	COLON
 
 	// One or two character tokens.
 	BANG
@@ -27,6 +30,10 @@ const (
 	GREATER_EQUAL
 	LESS
 	LESS_EQUAL
+	DOT_DOT_DOT
+	QUESTION_DOT
+	DOT_DOT
+	DOT_DOT_EQUAL
 
 	// Literals.
 	IDENTIFIER
@@ -35,12 +42,17 @@ const (
 
 	// Keywords.
 	AND
+	BREAK
 	CLASS
+	DIV
+	ELIF
 	ELSE
+	EPRINT
 	FALSE
 	FUN
 	FOR
 	IF
+	IN
 	NIL
 	OR
 	PRINT
@@ -48,6 +60,7 @@ const (
 	SUPER
 	THIS
 	TRUE
+	TYPEOF
 	VAR
 	WHILE
 	EOF
@@ -58,6 +71,10 @@ type Token struct {
 	Lexeme  string
 	Literal any
 	Line    int
+	// File is the original source file set by a #line directive, if any
+	File string `json:",omitempty"`
+	// NewlineBefore is set when a line break separates the token from the previous one
+	NewlineBefore bool `json:",omitempty"`
 }
 
 func New(t Type, lexeme string, literal any, line int) Token {
@@ -69,6 +86,18 @@ func New(t Type, lexeme string, literal any, line int) Token {
 	}
 }
 
+// NumberValue returns the value of a number literal token.
+func (t Token) NumberValue() (float64, bool) {
+	value, ok := t.Literal.(float64)
+	return value, ok
+}
+
+// StringValue returns the value of a string literal token.
+func (t Token) StringValue() (string, bool) {
+	value, ok := t.Literal.(string)
+	return value, ok
+}
+
 func (t Token) String() string {
 	return fmt.Sprintf("%s %s %v", t.Type, t.Lexeme, t.Literal)
 }
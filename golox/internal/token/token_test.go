@@ -0,0 +1,29 @@
+package token
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberValue(t *testing.T) {
+	value, ok := New(NUMBER, "1.5", 1.5, 1).NumberValue()
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, value)
+
+	_, ok = New(STRING, `"1.5"`, "1.5", 1).NumberValue()
+	assert.False(t, ok)
+	_, ok = New(IDENTIFIER, "x", nil, 1).NumberValue()
+	assert.False(t, ok)
+}
+
+func TestStringValue(t *testing.T) {
+	value, ok := New(STRING, `"hi"`, "hi", 1).StringValue()
+	assert.True(t, ok)
+	assert.Equal(t, "hi", value)
+
+	_, ok = New(NUMBER, "2", 2.0, 1).StringValue()
+	assert.False(t, ok)
+	_, ok = New(IDENTIFIER, "x", nil, 1).StringValue()
+	assert.False(t, ok)
+}
@@ -19,39 +19,52 @@ func _() {
 	_ = x[SEMICOLON-8]
 	_ = x[SLASH-9]
 	_ = x[STAR-10]
-	_ = x[BANG-11]
-	_ = x[BANG_EQUAL-12]
-	_ = x[EQUAL-13]
-	_ = x[EQUAL_EQUAL-14]
-	_ = x[GREATER-15]
-	_ = x[GREATER_EQUAL-16]
-	_ = x[LESS-17]
-	_ = x[LESS_EQUAL-18]
-	_ = x[IDENTIFIER-19]
-	_ = x[STRING-20]
-	_ = x[NUMBER-21]
-	_ = x[AND-22]
-	_ = x[CLASS-23]
-	_ = x[ELSE-24]
-	_ = x[FALSE-25]
-	_ = x[FUN-26]
-	_ = x[FOR-27]
-	_ = x[IF-28]
-	_ = x[NIL-29]
-	_ = x[OR-30]
-	_ = x[PRINT-31]
-	_ = x[RETURN-32]
-	_ = x[SUPER-33]
-	_ = x[THIS-34]
-	_ = x[TRUE-35]
-	_ = x[VAR-36]
-	_ = x[WHILE-37]
-	_ = x[EOF-38]
+	_ = x[LEFT_BRACKET-11]
+	_ = x[RIGHT_BRACKET-12]
+	_ = x[COLON-13]
+	_ = x[BANG-14]
+	_ = x[BANG_EQUAL-15]
+	_ = x[EQUAL-16]
+	_ = x[EQUAL_EQUAL-17]
+	_ = x[GREATER-18]
+	_ = x[GREATER_EQUAL-19]
+	_ = x[LESS-20]
+	_ = x[LESS_EQUAL-21]
+	_ = x[DOT_DOT_DOT-22]
+	_ = x[QUESTION_DOT-23]
+	_ = x[DOT_DOT-24]
+	_ = x[DOT_DOT_EQUAL-25]
+	_ = x[IDENTIFIER-26]
+	_ = x[STRING-27]
+	_ = x[NUMBER-28]
+	_ = x[AND-29]
+	_ = x[BREAK-30]
+	_ = x[CLASS-31]
+	_ = x[DIV-32]
+	_ = x[ELIF-33]
+	_ = x[ELSE-34]
+	_ = x[EPRINT-35]
+	_ = x[FALSE-36]
+	_ = x[FUN-37]
+	_ = x[FOR-38]
+	_ = x[IF-39]
+	_ = x[IN-40]
+	_ = x[NIL-41]
+	_ = x[OR-42]
+	_ = x[PRINT-43]
+	_ = x[RETURN-44]
+	_ = x[SUPER-45]
+	_ = x[THIS-46]
+	_ = x[TRUE-47]
+	_ = x[TYPEOF-48]
+	_ = x[VAR-49]
+	_ = x[WHILE-50]
+	_ = x[EOF-51]
 }
 
-const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
+const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARLEFT_BRACKETRIGHT_BRACKETCOLONBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALDOT_DOT_DOTQUESTION_DOTDOT_DOTDOT_DOT_EQUALIDENTIFIERSTRINGNUMBERANDBREAKCLASSDIVELIFELSEEPRINTFALSEFUNFORIFINNILORPRINTRETURNSUPERTHISTRUETYPEOFVARWHILEEOF"
 
-var _TokenType_index = [...]uint8{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 114, 127, 131, 141, 151, 157, 163, 166, 171, 175, 180, 183, 186, 188, 191, 193, 198, 204, 209, 213, 217, 220, 225, 228}
+var _TokenType_index = [...]uint16{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 89, 102, 107, 111, 121, 126, 137, 144, 157, 161, 171, 182, 194, 201, 214, 224, 230, 236, 239, 244, 249, 252, 256, 260, 266, 271, 274, 277, 279, 281, 284, 286, 291, 297, 302, 306, 310, 316, 319, 324, 327}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_TokenType_index)-1) {
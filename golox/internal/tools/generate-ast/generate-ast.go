@@ -109,12 +109,18 @@ func main() {
 	defineAst(outputDir, "Expr", []string{
 		"Assign   : Name token.Token, Value Expr",
 		"Binary   : Left Expr, Operator token.Token, Right Expr",
-		"Call     : Callee Expr, Paren token.Token, Arguments []Expr",
-		"Get      : Object Expr, Name token.Token",
+		"BlockExpr : Brace token.Token, Statements []Stmt, Value Expr",
+		"Call     : Callee Expr, Paren token.Token, Arguments []Expr, Spread []bool",
+		"Get      : Object Expr, Name token.Token, Optional bool",
 		"Grouping : Expression Expr",
+		"Lambda   : Keyword token.Token, Function *Function",
+		"List     : Bracket token.Token, Elements []Expr",
 		"Literal  : Value any",
+		"Range    : Start Expr, Operator token.Token, End Expr, Inclusive bool",
 		"Logical  : Left Expr, Operator token.Token, Right Expr",
 		"Set      : Object Expr, Name token.Token, Value Expr",
+		"Slice    : Object Expr, Bracket token.Token, Start Expr, End Expr",
+		"Subscript : Object Expr, Bracket token.Token, Index Expr",
 		"Super    : Keyword token.Token, Method token.Token",
 		"This     : Keyword token.Token",
 		"Unary    : Operator token.Token, Right Expr",
@@ -123,13 +129,18 @@ func main() {
 
 	defineAst(outputDir, "Stmt", []string{
 		"Block      : Statements []Stmt",
+		"Break      : Keyword token.Token",
 		"Class      : Name token.Token, Superclass *Variable, Methods []*Function",
+		"Destructure : Paren token.Token, Names []token.Token, Initializer Expr",
+		"Eprint     : Expression Expr",
 		"Expression : Expression Expr",
-		"Function   : Name token.Token, Params []token.Token, Body []Stmt",
+		"ForEach    : Keyword token.Token, Name token.Token, Iterable Expr, Body Stmt",
+		"Function   : Name token.Token, Params []token.Token, Body []Stmt, Getter bool",
 		"If         : Condition Expr, ThenBranch Stmt, ElseBranch Stmt",
-		"Print      : Expression Expr",
+		"MultiAssign : Targets []Expr, Equals token.Token, Values []Expr",
+		"Print      : Expressions []Expr",
 		"Return     : Keyword token.Token, Value Expr",
 		"Var 	    : Name token.Token, Initializer Expr",
-		"While      : Condition Expr, Body Stmt",
+		"While      : Keyword token.Token, Condition Expr, Body Stmt, Increment Expr, PerIteration bool, ElseBranch Stmt",
 	})
 }
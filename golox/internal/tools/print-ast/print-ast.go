@@ -1,55 +1,122 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 
+	"github.com/michael-go/lox/golox/internal/ast"
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/resolver"
 	"github.com/michael-go/lox/golox/internal/scanner"
 
 	"github.com/michael-go/go-jsn/jsn"
 )
 
-func printAst(source string) error {
-	scan := scanner.New(source)
-	tokens, err := scan.ScanTokens()
-	if err != nil {
-		return fmt.Errorf("faied to scan tokens: %w", err)
+// depths are the scope depths the resolver binds local variable expressions to
+type depths map[ast.Expr]int
+
+func (d depths) Resolve(expr ast.Expr, depth int, slot int) {
+	d[expr] = depth
+}
+
+var astPackage = reflect.TypeOf(ast.Variable{}).PkgPath()
+
+// annotate converts the AST to plain values for JSON, adding to every variable and
+// assignment the scope depth it resolved to, or "global"
+func annotate(value reflect.Value, resolved depths) any {
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			return nil
+		}
+		node := annotate(value.Elem(), resolved)
+		switch expr := value.Interface().(type) {
+		case *ast.Variable, *ast.Assign:
+			if depth, ok := resolved[expr.(ast.Expr)]; ok {
+				node.(map[string]any)["Depth"] = depth
+			} else {
+				node.(map[string]any)["Depth"] = "global"
+			}
+		}
+		return node
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		elements := make([]any, value.Len())
+		for i := range elements {
+			elements[i] = annotate(value.Index(i), resolved)
+		}
+		return elements
+	case reflect.Struct:
+		if value.Type().PkgPath() != astPackage {
+			return value.Interface()
+		}
+		fields := make(map[string]any)
+		for i := 0; i < value.NumField(); i++ {
+			fields[value.Type().Field(i).Name] = annotate(value.Field(i), resolved)
+		}
+		return fields
 	}
+	return value.Interface()
+}
+
+func astJson(source string, resolve bool) (string, error) {
+	scan := scanner.New(source)
+	// scan errors are already reported, keep parsing to report syntax errors too
+	tokens, scanErr := scan.ScanTokens()
 
 	parser := parser.New(tokens)
 	statements := parser.Parse()
-	if globals.HadError {
-		return fmt.Errorf("failed to parse")
+	if scanErr != nil || globals.HadError {
+		return "", fmt.Errorf("failed to parse")
 	}
 
-	json, err := jsn.NewJson(statements)
-	if err != nil {
-		return fmt.Errorf("failed to AST convert to json: %w", err)
+	var tree any = statements
+	if resolve {
+		resolved := depths{}
+		resolver := resolver.New(resolved)
+		resolver.Resolve(statements)
+		if globals.HadError {
+			return "", fmt.Errorf("failed to resolve")
+		}
+		tree = annotate(reflect.ValueOf(statements), resolved)
 	}
-	fmt.Println(json.Pretty())
 
-	return nil
+	json, err := jsn.NewJson(tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to AST convert to json: %w", err)
+	}
+	return json.Pretty(), nil
 }
 
 func main() {
-	if len(os.Args) != 2 {
-		fmt.Println("Usage: print-ast [lox source file]")
+	resolve := flag.Bool("resolved", false, "annotate variables with their resolved scope depth")
+	flag.Usage = func() {
+		fmt.Println("Usage: print-ast [--resolved] [lox source file]")
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	sourceFile := os.Args[1]
+	sourceFile := flag.Arg(0)
 	source, err := ioutil.ReadFile(sourceFile)
 	if err != nil {
 		fmt.Println("Could not read file:", err)
 		os.Exit(1)
 	}
 
-	err = printAst(string(source))
+	json, err := astJson(string(source), *resolve)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	fmt.Println(json)
 }
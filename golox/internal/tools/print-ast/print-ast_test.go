@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/michael-go/go-jsn/jsn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolvedDepths(t *testing.T) {
+	output, err := astJson(`
+		var a = 1;
+		{
+			var b = 2;
+			{
+				b = a + b;
+			}
+		}`, true)
+	assert.Nil(t, err)
+
+	json, err := jsn.NewJson(output)
+	assert.Nil(t, err)
+	assign := json.I(1).K("Statements").I(1).K("Statements").I(0).K("Expression")
+	assert.Equal(t, "b", assign.K("Name").K("Lexeme").String().Value)
+	assert.Equal(t, 1.0, assign.K("Depth").Float64().Value)
+	assert.Equal(t, "global", assign.K("Value").K("Left").K("Depth").String().Value)
+	assert.Equal(t, 1.0, assign.K("Value").K("Right").K("Depth").Float64().Value)
+}
+
+func TestUnresolvedHasNoDepths(t *testing.T) {
+	output, err := astJson(`{ var a = 1; print a; }`, false)
+	assert.Nil(t, err)
+
+	json, err := jsn.NewJson(output)
+	assert.Nil(t, err)
+	variable := json.I(0).K("Statements").I(1).K("Expressions").I(0)
+	assert.Equal(t, "a", variable.K("Name").K("Lexeme").String().Value)
+	assert.True(t, variable.K("Depth").Undefined())
+}
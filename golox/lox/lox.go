@@ -0,0 +1,158 @@
+// Package lox is the API for embedding the interpreter in Go programs.
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/michael-go/lox/golox/internal/ast"
+	"github.com/michael-go/lox/golox/internal/globals"
+	"github.com/michael-go/lox/golox/internal/interpreter"
+	"github.com/michael-go/lox/golox/internal/parser"
+	"github.com/michael-go/lox/golox/internal/resolver"
+	"github.com/michael-go/lox/golox/internal/scanner"
+)
+
+// Program is a parsed and resolved script, which can be run any number of times
+// without scanning and parsing it again.
+type Program struct {
+	statements []ast.Stmt
+	locals     bindings
+}
+
+// bindings keeps the resolved locals with the program, so they can be handed
+// to any interpreter running it
+type bindings map[ast.Expr]interpreter.Local
+
+func (b bindings) Resolve(expr ast.Expr, depth int, slot int) {
+	b[expr] = interpreter.Local{Depth: depth, Slot: slot}
+}
+
+// Options configures how a program is compiled.
+type Options struct {
+	// TopLevelReturn allows a script to end with `return value;` outside of any function,
+	// handing the value to the host through Session.Result
+	TopLevelReturn bool
+}
+
+// Session holds the global variables shared by the programs run in it.
+type Session struct {
+	interpreter interpreter.Interpreter
+	result      any
+}
+
+func NewSession() *Session {
+	return &Session{interpreter: interpreter.New()}
+}
+
+// SetOutput sets where print statements write to, stdout by default.
+func (s *Session) SetOutput(w io.Writer) {
+	s.interpreter.Print = func(str string) {
+		io.WriteString(w, str)
+	}
+}
+
+// Result returns the value of the top-level return statement of the last program run
+// in the session, or else the value of its last statement if that's an expression.
+func (s *Session) Result() any {
+	return s.result
+}
+
+// NewProgram compiles the source, returning all its syntax and resolution errors
+// if any. Errors are collected through the globals reporting hooks, so programs
+// shouldn't be compiled concurrently.
+func NewProgram(source string, opts ...Options) (*Program, error) {
+	var options Options
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+
+	program := &Program{locals: bindings{}}
+	err := collectErrors(func() {
+		scan := scanner.New(source)
+		tokens, _ := scan.ScanTokens()
+
+		parser := parser.New(tokens)
+		program.statements = parser.Parse()
+		if globals.HadError {
+			return
+		}
+
+		resolver := resolver.New(program.locals)
+		resolver.Options.TopLevelReturn = options.TopLevelReturn
+		resolver.Resolve(program.statements)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+// Eval evaluates a single expression, like `1 + 2 * 3`, in a fresh session and returns
+// its value.
+func Eval(expr string) (any, error) {
+	program := &Program{locals: bindings{}}
+	err := collectErrors(func() {
+		scan := scanner.New(expr)
+		tokens, _ := scan.ScanTokens()
+
+		parser := parser.New(tokens)
+		parsed := parser.ParseExpression()
+		if globals.HadError {
+			return
+		}
+		program.statements = []ast.Stmt{&ast.Expression{Expression: parsed}}
+
+		resolver := resolver.New(program.locals)
+		resolver.Resolve(program.statements)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session := NewSession()
+	if err := program.Run(session); err != nil {
+		return nil, err
+	}
+	return session.Result(), nil
+}
+
+// Run runs the program in the session, or in a fresh one if session is nil.
+func (p *Program) Run(session *Session) error {
+	if session == nil {
+		session = NewSession()
+	}
+	for expr, local := range p.locals {
+		session.interpreter.Locals[expr] = local
+	}
+	result, err := session.interpreter.Run(p.statements)
+	session.result = result
+	return err
+}
+
+func collectErrors(f func()) error {
+	origReport := globals.Report
+	origHadError := globals.HadError
+	defer func() {
+		globals.Report = origReport
+		globals.HadError = origHadError
+	}()
+
+	var messages []string
+	globals.HadError = false
+	globals.Report = func(severity globals.Severity, file string, line int, where string, message string) {
+		// warnings that don't fail the program aren't returned
+		if severity == globals.SeverityError || globals.WarningsAsErrors {
+			messages = append(messages, fmt.Sprintf("[%s] %s%s: %s", globals.Location(file, line), severity, where, message))
+			globals.HadError = true
+		}
+	}
+
+	f()
+	if len(messages) != 0 {
+		return errors.New(strings.Join(messages, "\n"))
+	}
+	return nil
+}
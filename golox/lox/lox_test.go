@@ -0,0 +1,129 @@
+package lox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const counterCode = `
+	var count = 0;
+	fun makeCounter() {
+		var i = 0;
+		fun next() {
+			i = i + 1;
+			return i;
+		}
+		return next;
+	}
+	var next = makeCounter();
+	next();
+	count = next();
+	print count;
+`
+
+func TestRunProgramTwice(t *testing.T) {
+	program, err := NewProgram(counterCode)
+	assert.Nil(t, err)
+
+	var output strings.Builder
+	for i := 0; i < 2; i++ {
+		session := NewSession()
+		session.SetOutput(&output)
+		assert.Nil(t, program.Run(session))
+	}
+	assert.Equal(t, "2\n2\n", output.String())
+}
+
+func TestSharedSession(t *testing.T) {
+	define, err := NewProgram(`var total = 0;`)
+	assert.Nil(t, err)
+	add, err := NewProgram(`{ var step = 5; total = total + step; } print total;`)
+	assert.Nil(t, err)
+
+	var output strings.Builder
+	session := NewSession()
+	session.SetOutput(&output)
+	assert.Nil(t, define.Run(session))
+	assert.Nil(t, add.Run(session))
+	assert.Nil(t, add.Run(session))
+	assert.Equal(t, "5\n10\n", output.String())
+}
+
+func TestProgramErrors(t *testing.T) {
+	_, err := NewProgram("print 1 +;\nreturn 2;")
+	assert.EqualError(t, err, "[line 1] Error at ';': Expect expression.")
+
+	_, err = NewProgram("return 2;")
+	assert.EqualError(t, err, "[line 1] Error at 'return': Can't return from top-level code.")
+
+	program, err := NewProgram("print 1;\nprint -\"a\";")
+	assert.Nil(t, err)
+	var output strings.Builder
+	session := NewSession()
+	session.SetOutput(&output)
+	assert.EqualError(t, program.Run(session), "Operand must be a number.\n[line 2]")
+	assert.Equal(t, "1\n", output.String())
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	code := `
+		var total = 0;
+		for (var i = 1; i <= 4; i = i + 1) {
+			total = total + i;
+			if (total > 5) return total * 10;
+		}
+		print "not reached";
+	`
+	_, err := NewProgram(code)
+	assert.EqualError(t, err, "[line 5] Error at 'return': Can't return from top-level code.")
+
+	program, err := NewProgram(code, Options{TopLevelReturn: true})
+	assert.Nil(t, err)
+	var output strings.Builder
+	session := NewSession()
+	session.SetOutput(&output)
+	assert.Nil(t, program.Run(session))
+	assert.Equal(t, 60.0, session.Result())
+	assert.Equal(t, "", output.String())
+
+	program, err = NewProgram("print 1;", Options{TopLevelReturn: true})
+	assert.Nil(t, err)
+	session.SetOutput(&output)
+	assert.Nil(t, program.Run(session))
+	assert.Nil(t, session.Result())
+}
+
+func TestEval(t *testing.T) {
+	value, err := Eval("1 + 2 * 3")
+	assert.Nil(t, err)
+	assert.Equal(t, 7.0, value)
+
+	value, err = Eval(`"a" + "b"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "ab", value)
+
+	_, err = Eval("1 +")
+	assert.EqualError(t, err, "[line 1] Error at end: Expect expression.")
+
+	_, err = Eval("1 + 2; print 3;")
+	assert.EqualError(t, err, "[line 1] Error at ';': Expect end of expression.")
+
+	_, err = Eval("-nil")
+	assert.EqualError(t, err, "Operand must be a number.\n[line 1]")
+}
+
+func BenchmarkRunProgram(b *testing.B) {
+	program, err := NewProgram(counterCode)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var output strings.Builder
+	for i := 0; i < b.N; i++ {
+		session := NewSession()
+		session.SetOutput(&output)
+		program.Run(session)
+	}
+}
@@ -2,62 +2,193 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/michael-go/lox/golox/internal/ast"
 	"github.com/michael-go/lox/golox/internal/globals"
 	"github.com/michael-go/lox/golox/internal/interpreter"
 	"github.com/michael-go/lox/golox/internal/parser"
 	"github.com/michael-go/lox/golox/internal/resolver"
 	"github.com/michael-go/lox/golox/internal/scanner"
+	"github.com/michael-go/lox/golox/internal/token"
 )
 
-func run(interpreter *interpreter.Interpreter, source string) error {
+// errors already reported to the user while running a script
+var (
+	errParse   = errors.New("failed to parse")
+	errResolve = errors.New("failed to resolve")
+	errRuntime = errors.New("runtime error")
+)
+
+// exit codes from sysexits.h
+const (
+	exitDataErr  = 65
+	exitNoInput  = 66
+	exitSoftware = 70
+	exitIOErr    = 74
+	exitNoPerm   = 77
+)
+
+// compile scans, parses and resolves the source, reporting all the errors found
+func compile(interpreter *interpreter.Interpreter, source string) ([]ast.Stmt, error) {
 	scan := scanner.New(source)
-	tokens, err := scan.ScanTokens()
-	if err != nil {
-		return fmt.Errorf("faied to scan tokens: %w", err)
-	}
+	// scan errors are already reported, keep parsing to report syntax errors too
+	tokens, scanErr := scan.ScanTokens()
 
 	parser := parser.New(tokens)
 	statements := parser.Parse()
-	if globals.HadError {
-		return fmt.Errorf("failed to parse")
+	if scanErr != nil || globals.HadError {
+		return nil, errParse
 	}
 
 	resolver := resolver.New(interpreter)
+	resolver.DeclareNatives(interpreter.NativeNames()...)
 	resolver.Resolve(statements)
 	if globals.HadError {
-		return fmt.Errorf("failed to resolve")
+		return nil, errResolve
+	}
+	return statements, nil
+}
+
+func run(interpreter *interpreter.Interpreter, source string) error {
+	statements, err := compile(interpreter, source)
+	if err != nil {
+		return err
 	}
 
 	interpreter.Interpret(statements)
+	if globals.HadRuntimeError {
+		return errRuntime
+	}
 	return nil
 }
 
-func runFile(path string) error {
+func readFile(path string) (string, error) {
 	content, err := ioutil.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("could not find file: %w", err)
+	} else if err != nil {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	return string(content), nil
+}
+
+func runFile(path string) error {
+	source, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	interpreter := interpreter.New()
+
+	defer globals.FlushRuntimeErrors()
+	return run(&interpreter, source)
+}
+
+// checkFile reports the errors in a script without running it
+func checkFile(path string) error {
+	source, err := readFile(path)
 	if err != nil {
-		return fmt.Errorf("could not read file: %w", err)
+		return err
 	}
 
 	interpreter := interpreter.New()
 
-	run(&interpreter, string(content))
+	_, err = compile(&interpreter, source)
+	return err
+}
 
+// formatTokens writes the tokens one per line with their type, lexeme, literal and line
+func formatTokens(w io.Writer, tokens []token.Token) {
+	for _, tok := range tokens {
+		fmt.Fprintf(w, "%v %d\n", tok, tok.Line)
+	}
+}
+
+// dumpTokens prints the token stream of a script without parsing it
+func dumpTokens(path string) error {
+	source, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	scan := scanner.New(source)
+	tokens, err := scan.ScanTokens()
+	if err != nil {
+		return errParse
+	}
+	formatTokens(os.Stdout, tokens)
 	return nil
 }
 
+// exitCode maps the error of running a script to the process exit code
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errParse), errors.Is(err, errResolve):
+		return exitDataErr
+	case errors.Is(err, errRuntime):
+		return exitSoftware
+	case errors.Is(err, fs.ErrNotExist):
+		return exitNoInput
+	case errors.Is(err, fs.ErrPermission):
+		return exitNoPerm
+	}
+	return exitIOErr
+}
+
+// terminals in bracketed paste mode wrap pasted text with these sequences
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// readInput reads the next line, or all the lines of a bracketed paste so that a
+// pasted block runs as a whole
+func readInput(reader *bufio.Reader) (string, error) {
+	input, err := reader.ReadString('\n')
+	start := strings.Index(input, pasteStart)
+	if start < 0 {
+		return input, err
+	}
+
+	for err == nil && !strings.Contains(input[start:], pasteEnd) {
+		var line string
+		line, err = reader.ReadString('\n')
+		input += line
+	}
+	input = strings.Replace(input, pasteStart, "", 1)
+	input = strings.Replace(input, pasteEnd, "", 1)
+	return input, err
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func runPrompt() error {
 	interpreter := interpreter.New()
+	defer globals.FlushRuntimeErrors()
 
 	reader := bufio.NewReader(os.Stdin)
 
+	if isTerminal(os.Stdin) {
+		fmt.Print("\x1b[?2004h")
+		defer fmt.Print("\x1b[?2004l")
+	}
+
 	for {
 		fmt.Print("> ")
-		line, err := reader.ReadString('\n')
+		line, err := readInput(reader)
 		if err == io.EOF {
 			break
 		} else if err != nil {
@@ -70,17 +201,32 @@ func runPrompt() error {
 }
 
 func main() {
+	check := flag.Bool("check", false, "parse and resolve the script without running it")
+	tokens := flag.Bool("dump-tokens", false, "print the tokens of the script without running it")
+	collapse := flag.Bool("collapse-errors", false, "report repeated identical runtime errors once with a count")
+	flag.Usage = func() {
+		fmt.Println("Usage: golox [--check | --dump-tokens] [--collapse-errors] [script]")
+	}
+	flag.Parse()
+	globals.CollapseRepeatedRuntimeErrors = *collapse
+
 	var err error
 
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: golox [script]")
-	} else if len(os.Args) == 2 {
-		err = runFile(os.Args[1])
-		if globals.HadError {
-			os.Exit(65)
-		} else if globals.HadRuntimeError {
-			os.Exit(70)
+	if flag.NArg() > 1 || ((*check || *tokens) && flag.NArg() == 0) {
+		flag.Usage()
+	} else if flag.NArg() == 1 {
+		if *check {
+			err = checkFile(flag.Arg(0))
+		} else if *tokens {
+			err = dumpTokens(flag.Arg(0))
+		} else {
+			err = runFile(flag.Arg(0))
+		}
+		code := exitCode(err)
+		if code != 0 && code != exitDataErr && code != exitSoftware {
+			fmt.Fprintln(os.Stderr, err)
 		}
+		os.Exit(code)
 	} else {
 		err = runPrompt()
 	}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michael-go/lox/golox/internal/globals"
+	"github.com/michael-go/lox/golox/internal/interpreter"
+	"github.com/michael-go/lox/golox/internal/scanner"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFileNotFound(t *testing.T) {
+	err := runFile(filepath.Join(t.TempDir(), "missing.lox"))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.Contains(t, err.Error(), "could not find file")
+	assert.Equal(t, 66, exitCode(err))
+}
+
+func TestRunFileNotReadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	path := filepath.Join(t.TempDir(), "secret.lox")
+	assert.Nil(t, os.WriteFile(path, []byte("print 1;"), 0))
+
+	err := runFile(path)
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	assert.Equal(t, 77, exitCode(err))
+}
+
+func TestRunFileFlushesRepeatedErrors(t *testing.T) {
+	var output strings.Builder
+	origOutput := globals.RuntimeErrorOutput
+	defer func() {
+		globals.RuntimeErrorOutput = origOutput
+		globals.Reset()
+	}()
+	globals.RuntimeErrorOutput = &output
+	globals.CollapseRepeatedRuntimeErrors = true
+
+	path := filepath.Join(t.TempDir(), "fail.lox")
+	assert.Nil(t, os.WriteFile(path, []byte("-nil;"), 0o644))
+
+	interpreter := interpreter.New()
+	assert.ErrorIs(t, run(&interpreter, "-nil;"), errRuntime)
+	assert.ErrorIs(t, runFile(path), errRuntime)
+	assert.Equal(t, "Operand must be a number.\n[line 1]\n(repeated 1 time)\n", output.String())
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, 65, exitCode(errParse))
+	assert.Equal(t, 65, exitCode(errResolve))
+	assert.Equal(t, 70, exitCode(errRuntime))
+}
+
+func TestFormatTokens(t *testing.T) {
+	scan := scanner.New("var x = \"hi\";\nprint x + 1;")
+	tokens, err := scan.ScanTokens()
+	assert.Nil(t, err)
+
+	var out strings.Builder
+	formatTokens(&out, tokens)
+	assert.Equal(t, `VAR var <nil> 1
+IDENTIFIER x <nil> 1
+EQUAL = <nil> 1
+STRING "hi" hi 1
+SEMICOLON ; <nil> 1
+PRINT print <nil> 2
+IDENTIFIER x <nil> 2
+PLUS + <nil> 2
+NUMBER 1 1 2
+SEMICOLON ; <nil> 2
+EOF  <nil> 2
+`, out.String())
+}
@@ -1,49 +1,79 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
-func createExpectedOutputs() {
-	const dirPrefix = "tests/fixtures/"
-	fileInfos, err := ioutil.ReadDir(dirPrefix)
+// matchingFixtures returns the names of the .lox fixtures in dir, without the extension, whose
+// name matches the filter glob pattern. An empty filter matches all fixtures.
+func matchingFixtures(dir string, filter string) ([]string, error) {
+	if _, err := filepath.Match(filter, ""); err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", filter, err)
+	}
+
+	fileInfos, err := ioutil.ReadDir(dir)
 	if err != nil {
-		panic(fmt.Errorf("could not read fixtures directory: %v", err))
+		return nil, fmt.Errorf("could not read fixtures directory: %w", err)
 	}
+
+	var names []string
 	for _, fileInfo := range fileInfos {
-		if strings.HasSuffix(fileInfo.Name(), ".lox") {
-			loxPath := dirPrefix + fileInfo.Name()
-			expectedPath := dirPrefix + strings.TrimSuffix(fileInfo.Name(), ".lox") + ".out"
-
-			fmt.Println("Generating ", expectedPath)
-
-			cmd := exec.Command("go", "run", "main.go", loxPath)
-			stdout, err := cmd.Output()
-			stderr := ""
-			if err != nil {
-				exitError, ok := err.(*exec.ExitError)
-				if !ok {
-					panic(fmt.Errorf("failed to run lox file %s, err: %v", loxPath, err))
-				}
-				stderr = string(exitError.Stderr)
+		if !strings.HasSuffix(fileInfo.Name(), ".lox") {
+			continue
+		}
+		name := strings.TrimSuffix(fileInfo.Name(), ".lox")
+		if filter != "" {
+			if matched, _ := filepath.Match(filter, name); !matched {
+				continue
 			}
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
 
-			var expected strings.Builder
-			expected.WriteString(fmt.Sprintf("# exit code: %d\n", cmd.ProcessState.ExitCode()))
-			expected.WriteString(fmt.Sprintf("# stdout:\n%s\n", string(stdout)))
-			expected.WriteString(fmt.Sprintf("# stderr:\n%s\n", string(stderr)))
+func createExpectedOutputs(filter string) {
+	const dirPrefix = "tests/fixtures/"
+	names, err := matchingFixtures(dirPrefix, filter)
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range names {
+		loxPath := dirPrefix + name + ".lox"
+		expectedPath := dirPrefix + name + ".out"
+
+		fmt.Println("Generating ", expectedPath)
 
-			err = ioutil.WriteFile(expectedPath, []byte(expected.String()), 0644)
-			if err != nil {
-				panic(fmt.Errorf("could not write expected output: %v", err))
+		cmd := exec.Command("go", "run", "main.go", loxPath)
+		stdout, err := cmd.Output()
+		stderr := ""
+		if err != nil {
+			exitError, ok := err.(*exec.ExitError)
+			if !ok {
+				panic(fmt.Errorf("failed to run lox file %s, err: %v", loxPath, err))
 			}
+			stderr = string(exitError.Stderr)
+		}
+
+		var expected strings.Builder
+		expected.WriteString(fmt.Sprintf("# exit code: %d\n", cmd.ProcessState.ExitCode()))
+		expected.WriteString(fmt.Sprintf("# stdout:\n%s\n", string(stdout)))
+		expected.WriteString(fmt.Sprintf("# stderr:\n%s\n", string(stderr)))
+
+		err = ioutil.WriteFile(expectedPath, []byte(expected.String()), 0644)
+		if err != nil {
+			panic(fmt.Errorf("could not write expected output: %v", err))
 		}
 	}
 }
 
 func main() {
-	createExpectedOutputs()
+	filter := flag.String("filter", "", "only regenerate fixtures whose name matches this glob pattern, e.g. 'runtime-*'")
+	flag.Parse()
+	createExpectedOutputs(*filter)
 }
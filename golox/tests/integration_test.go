@@ -71,3 +71,46 @@ func TestIntegration(t *testing.T) {
 
 	assert.Greater(t, testsCount, 0)
 }
+
+func TestMatchingFixtures(t *testing.T) {
+	names, err := matchingFixtures("fixtures", "inheritance-*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"inheritance-constructor", "inheritance-not-from-class", "inheritance-of-itself"}, names)
+
+	all, err := matchingFixtures("fixtures", "")
+	assert.Nil(t, err)
+	assert.Contains(t, all, "inheritance")
+	assert.Contains(t, all, "loops")
+
+	_, err = matchingFixtures("fixtures", "[")
+	assert.Error(t, err)
+}
+
+func TestReplBracketedPaste(t *testing.T) {
+	cmd := exec.Command("go", "run", "../main.go")
+	cmd.Stdin = strings.NewReader("print 1;\n\x1b[200~var a =\n  2;\nprint a +\n  1;\x1b[201~\nprint a;\n")
+	stdout, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("failed to run the REPL: %v", err)
+	}
+	assert.Equal(t, "> 1\n> 3\n> 2\n> ", string(stdout))
+}
+
+func TestCheck(t *testing.T) {
+	cmd := exec.Command("go", "run", "../main.go", "--check", "fixtures/classes.lox")
+	stdout, err := cmd.Output()
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(stdout))
+
+	cmd = exec.Command("go", "run", "../main.go", "--check", "fixtures/semantic-errors.lox")
+	stdout, err = cmd.Output()
+	exitError, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("expected the check to fail, got: %v", err)
+	}
+	assert.NotEqual(t, 0, cmd.ProcessState.ExitCode())
+	assert.Equal(t, "", string(stdout))
+	assert.Contains(t, string(exitError.Stderr), "Error at")
+	// go run reports the exit code of the program it ran
+	assert.Contains(t, string(exitError.Stderr), "exit status 65")
+}